@@ -0,0 +1,142 @@
+// Package capture reads packet capture files (pcap and pcapng) and extracts
+// the TLS client hellos and cleartext HTTP requests sent by the client side of
+// each TCP connection, without relying on any external tools.
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrorUnknownFormat indicates that the input is neither a pcap nor a
+	// pcapng file.
+	ErrorUnknownFormat = errors.New("capture: unknown file format")
+)
+
+// A Flow contains the client hellos and HTTP requests sent by the client of a
+// single TCP connection.
+type Flow struct {
+	// Client is the address of the endpoint that initiated the connection.
+	Client string
+
+	// Server is the address of the endpoint that accepted the connection.
+	Server string
+
+	// ClientHellos contains each client hello handshake message sent by the
+	// client, starting with the handshake type byte, or the whole record for
+	// an SSL 2.0 compatible client hello.
+	ClientHellos [][]byte
+
+	// HTTPRequests contains each cleartext HTTP request sent by the client.
+	HTTPRequests []HTTPRequest
+}
+
+// An HTTPRequest is a cleartext HTTP request sent by a client.
+type HTTPRequest struct {
+	// RequestLine is the first line of the request, such as "GET / HTTP/1.1".
+	RequestLine string
+
+	// HeaderLines contains the raw header lines in the order they were sent,
+	// without line terminators.
+	HeaderLines []string
+}
+
+// Header returns the value of the first header with the given name, compared
+// case-insensitively, or an empty string if the header is not present.
+func (a HTTPRequest) Header(name string) string {
+	for _, line := range a.HeaderLines {
+		if idx := strings.IndexByte(line, ':'); idx != -1 && strings.EqualFold(strings.TrimSpace(line[:idx]), name) {
+			return strings.TrimSpace(line[idx+1:])
+		}
+	}
+	return ""
+}
+
+// HeaderNames returns the lowercase names of the request headers in the order
+// they were sent.
+func (a HTTPRequest) HeaderNames() []string {
+	var names []string
+	for _, line := range a.HeaderLines {
+		if idx := strings.IndexByte(line, ':'); idx != -1 {
+			names = append(names, strings.ToLower(strings.TrimSpace(line[:idx])))
+		}
+	}
+	return names
+}
+
+// ReadFile reads the capture file with the given name and returns its flows.
+func ReadFile(fileName string) ([]Flow, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	flows, err := Read(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", fileName, err)
+	}
+	return flows, nil
+}
+
+// Read reads a pcap or pcapng capture and returns the TCP flows it contains,
+// in the order in which they were first seen. Captures that end with a
+// truncated packet are processed up to the truncation.
+func Read(input io.Reader) ([]Flow, error) {
+	reader := bufio.NewReader(input)
+	magic, err := reader.Peek(4)
+	if err != nil {
+		return nil, ErrorUnknownFormat
+	}
+	var packets packetReader
+	switch {
+	case bytes.Equal(magic, pcapngBlockTypeSHB):
+		packets = newPcapngReader(reader)
+	case binary.LittleEndian.Uint32(magic) == pcapMagicMicro,
+		binary.BigEndian.Uint32(magic) == pcapMagicMicro,
+		binary.LittleEndian.Uint32(magic) == pcapMagicNano,
+		binary.BigEndian.Uint32(magic) == pcapMagicNano:
+		if packets, err = newPcapReader(reader); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrorUnknownFormat
+	}
+
+	var assembler assembler
+	for {
+		linkType, data, err := packets.next()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if segment, ok := decodeTCP(linkType, data); ok {
+			assembler.add(segment)
+		}
+	}
+	return assembler.flows(), nil
+}
+
+// LastClientHello returns the last client hello sent in any of the flows, or
+// nil if there is none.
+func LastClientHello(flows []Flow) []byte {
+	for i := len(flows) - 1; i >= 0; i-- {
+		if hellos := flows[i].ClientHellos; len(hellos) > 0 {
+			return hellos[len(hellos)-1]
+		}
+	}
+	return nil
+}
+
+// A packetReader returns the link type and data of each packet in a capture.
+type packetReader interface {
+	next() (uint32, []byte, error)
+}
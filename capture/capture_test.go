@@ -0,0 +1,199 @@
+package capture_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/mitmengine/capture"
+	fp "github.com/cloudflare/mitmengine/fputil"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+var pcapDir = filepath.Join("..", "reference_fingerprints", "pcaps")
+
+// A testPacket is a TCP segment sent over IPv4.
+type testPacket struct {
+	src, dst         [4]byte
+	srcPort, dstPort uint16
+	seq              uint32
+	flags            uint8
+	payload          []byte
+}
+
+var (
+	clientAddr = [4]byte{10, 0, 0, 1}
+	serverAddr = [4]byte{10, 0, 0, 2}
+)
+
+func toServer(seq uint32, flags uint8, payload string) testPacket {
+	return testPacket{clientAddr, serverAddr, 50000, 443, seq, flags, []byte(payload)}
+}
+
+func toClient(seq uint32, flags uint8, payload string) testPacket {
+	return testPacket{serverAddr, clientAddr, 443, 50000, seq, flags, []byte(payload)}
+}
+
+// ethernetFrame encodes the packet as an Ethernet frame.
+func (a testPacket) ethernetFrame() []byte {
+	frame := make([]byte, 14+20+20)
+	binary.BigEndian.PutUint16(frame[12:14], 0x0800)
+	ip := frame[14:]
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:4], uint16(40+len(a.payload)))
+	ip[9] = 6
+	copy(ip[12:16], a.src[:])
+	copy(ip[16:20], a.dst[:])
+	tcp := ip[20:]
+	binary.BigEndian.PutUint16(tcp[0:2], a.srcPort)
+	binary.BigEndian.PutUint16(tcp[2:4], a.dstPort)
+	binary.BigEndian.PutUint32(tcp[4:8], a.seq)
+	tcp[12] = 5 << 4
+	tcp[13] = a.flags
+	return append(frame, a.payload...)
+}
+
+// writePcap encodes the packets as a little-endian pcap file.
+func writePcap(packets []testPacket) []byte {
+	var buf bytes.Buffer
+	header := []uint32{0xa1b2c3d4, 0x00040002, 0, 0, 0xffff, 1}
+	binary.Write(&buf, binary.LittleEndian, header)
+	for _, packet := range packets {
+		frame := packet.ethernetFrame()
+		binary.Write(&buf, binary.LittleEndian, []uint32{0, 0, uint32(len(frame)), uint32(len(frame))})
+		buf.Write(frame)
+	}
+	return buf.Bytes()
+}
+
+// writePcapng encodes the packets as a big-endian pcapng file with a single
+// Ethernet interface.
+func writePcapng(packets []testPacket) []byte {
+	var buf bytes.Buffer
+	writeBlock := func(blockType uint32, body []byte) {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		binary.Write(&buf, binary.BigEndian, []uint32{blockType, uint32(len(body) + 12)})
+		buf.Write(body)
+		binary.Write(&buf, binary.BigEndian, uint32(len(body)+12))
+	}
+	writeBlock(0x0a0d0d0a, []byte{0x1a, 0x2b, 0x3c, 0x4d, 0, 1, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	writeBlock(0x00000001, []byte{0, 1, 0, 0, 0, 0, 0xff, 0xff})
+	for _, packet := range packets {
+		frame := packet.ethernetFrame()
+		var body bytes.Buffer
+		binary.Write(&body, binary.BigEndian, []uint32{0, 0, 0, uint32(len(frame)), uint32(len(frame))})
+		body.Write(frame)
+		writeBlock(0x00000006, body.Bytes())
+	}
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	hello := "\x16\x03\x01\x00\x08\x01\x00\x00\x04\x03\x03\xab\xcd"
+	request := "GET / HTTP/1.1\r\nHost: example.com\r\nUser-Agent: test\r\n\r\n"
+	packets := []testPacket{
+		toServer(99, 0x02, ""),
+		toClient(499, 0x12, ""),
+		// the client hello arrives out of order and is partly retransmitted
+		toServer(105, 0x18, hello[5:]),
+		toServer(100, 0x18, hello[:7]),
+		toServer(100, 0x18, hello[:5]),
+		toClient(500, 0x18, "\x16\x03\x03\x00\x00"),
+		toServer(100+uint32(len(hello)), 0x18, "\x17\x03\x03\x00\x00"),
+		// a new syn reuses the port pair for a cleartext connection
+		toServer(999, 0x02, ""),
+		toServer(1000, 0x18, request[:20]),
+		toServer(1020, 0x18, request[20:]),
+	}
+	expected := []capture.Flow{
+		{
+			Client:       "10.0.0.1:50000",
+			Server:       "10.0.0.2:443",
+			ClientHellos: [][]byte{[]byte(hello[5:])},
+		},
+		{
+			Client: "10.0.0.1:50000",
+			Server: "10.0.0.2:443",
+			HTTPRequests: []capture.HTTPRequest{{
+				RequestLine: "GET / HTTP/1.1",
+				HeaderLines: []string{"Host: example.com", "User-Agent: test"},
+			}},
+		},
+	}
+	for _, data := range [][]byte{writePcap(packets), writePcapng(packets)} {
+		actual, err := capture.Read(bytes.NewReader(data))
+		testutil.Ok(t, err)
+		testutil.Equals(t, expected, actual)
+	}
+}
+
+func TestReadSSLv2(t *testing.T) {
+	hello := "\x80\x0c\x01\x03\x01\x00\x03\x00\x00\x00\x00\x00\x00\x2f"
+	packets := []testPacket{
+		toServer(99, 0x02, ""),
+		toServer(100, 0x18, hello),
+		toServer(100+uint32(len(hello)), 0x18, "\x16\x03\x01\x00\x00"),
+	}
+	expected := []capture.Flow{{
+		Client:       "10.0.0.1:50000",
+		Server:       "10.0.0.2:443",
+		ClientHellos: [][]byte{[]byte(hello)},
+	}}
+	actual, err := capture.Read(bytes.NewReader(writePcap(packets)))
+	testutil.Ok(t, err)
+	testutil.Equals(t, expected, actual)
+	fingerprint, err := fp.ParseClientHello(actual[0].ClientHellos[0])
+	testutil.Ok(t, err)
+	testutil.Equals(t, "301:2f:::::v2", fingerprint.String())
+}
+
+func TestReadUnknownFormat(t *testing.T) {
+	var tests = [][]byte{
+		nil,
+		[]byte("GET / HTTP/1.1\r\n"),
+	}
+	for _, test := range tests {
+		_, err := capture.Read(bytes.NewReader(test))
+		testutil.Equals(t, capture.ErrorUnknownFormat, err)
+	}
+}
+
+func TestHTTPRequestHeader(t *testing.T) {
+	request := capture.HTTPRequest{
+		RequestLine: "POST /submit HTTP/1.1",
+		HeaderLines: []string{"Host: example.com", "user-agent:  test/1.0 ", "Content-Length: 0"},
+	}
+	testutil.Equals(t, "test/1.0", request.Header("User-Agent"))
+	testutil.Equals(t, "", request.Header("Accept"))
+	testutil.Equals(t, []string{"host", "user-agent", "content-length"}, request.HeaderNames())
+}
+
+// TestReadReferencePcaps checks that every reference capture can be read, and
+// that every client hello found in them can be fingerprinted.
+func TestReadReferencePcaps(t *testing.T) {
+	var numFiles, numHellos int
+	err := filepath.Walk(pcapDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".pcap") {
+			return err
+		}
+		numFiles++
+		flows, err := capture.ReadFile(path)
+		testutil.Ok(t, err)
+		for _, flow := range flows {
+			for _, hello := range flow.ClientHellos {
+				_, err := fp.ParseClientHello(hello)
+				testutil.Ok(t, err)
+				numHellos++
+			}
+		}
+		return nil
+	})
+	testutil.Ok(t, err)
+	testutil.Assert(t, numFiles > 0, "no reference pcaps found in %s", pcapDir)
+	testutil.Assert(t, numHellos >= numFiles, "found %d client hellos in %d reference pcaps", numHellos, numFiles)
+}
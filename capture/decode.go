@@ -0,0 +1,198 @@
+package capture
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+)
+
+// Link-layer header types.
+// Source:
+//  - https://www.tcpdump.org/linktypes.html
+const (
+	linkTypeNull     uint32 = 0
+	linkTypeEthernet uint32 = 1
+	linkTypeRaw      uint32 = 101
+	linkTypeLoop     uint32 = 108
+	linkTypeLinuxSLL uint32 = 113
+	linkTypeIPv4     uint32 = 228
+	linkTypeIPv6     uint32 = 229
+	linkTypeSLL2     uint32 = 276
+)
+
+// EtherTypes and IP protocol numbers.
+const (
+	etherTypeIPv4 uint16 = 0x0800
+	etherTypeIPv6 uint16 = 0x86dd
+	etherTypeVLAN uint16 = 0x8100
+	etherTypeQinQ uint16 = 0x88a8
+	ipProtocolTCP uint8  = 6
+	ipv6HopByHop  uint8  = 0
+	ipv6Routing   uint8  = 43
+	ipv6Fragment  uint8  = 44
+	ipv6DestOpts  uint8  = 60
+	tcpFlagSyn    uint8  = 0x02
+	tcpFlagAck    uint8  = 0x10
+)
+
+// A segment is a decoded TCP segment.
+type segment struct {
+	src     string
+	dst     string
+	srcPort uint16
+	dstPort uint16
+	seq     uint32
+	flags   uint8
+	payload []byte
+}
+
+// decodeTCP decodes the link, network, and transport layers of a packet, and
+// returns the TCP segment it carries, if any.
+func decodeTCP(linkType uint32, data []byte) (segment, bool) {
+	var etherType uint16
+	switch linkType {
+	case linkTypeEthernet:
+		if len(data) < 14 {
+			return segment{}, false
+		}
+		etherType = binary.BigEndian.Uint16(data[12:14])
+		data = data[14:]
+		for (etherType == etherTypeVLAN || etherType == etherTypeQinQ) && len(data) >= 4 {
+			etherType = binary.BigEndian.Uint16(data[2:4])
+			data = data[4:]
+		}
+	case linkTypeLinuxSLL:
+		if len(data) < 16 {
+			return segment{}, false
+		}
+		etherType = binary.BigEndian.Uint16(data[14:16])
+		data = data[16:]
+	case linkTypeSLL2:
+		if len(data) < 20 {
+			return segment{}, false
+		}
+		etherType = binary.BigEndian.Uint16(data[0:2])
+		data = data[20:]
+	case linkTypeNull, linkTypeLoop:
+		// the address family is in host byte order for null and network
+		// byte order for loop, so rely on the IP version instead
+		if len(data) < 4 {
+			return segment{}, false
+		}
+		data = data[4:]
+	case linkTypeRaw, linkTypeIPv4, linkTypeIPv6:
+	default:
+		return segment{}, false
+	}
+	if etherType == 0 && len(data) > 0 {
+		switch data[0] >> 4 {
+		case 4:
+			etherType = etherTypeIPv4
+		case 6:
+			etherType = etherTypeIPv6
+		}
+	}
+
+	var s segment
+	var ok bool
+	switch etherType {
+	case etherTypeIPv4:
+		data, ok = decodeIPv4(&s, data)
+	case etherTypeIPv6:
+		data, ok = decodeIPv6(&s, data)
+	}
+	if !ok {
+		return segment{}, false
+	}
+	return s, decodeTCPHeader(&s, data)
+}
+
+// decodeIPv4 fills in the addresses of the segment and returns the IPv4
+// payload if it carries an unfragmented TCP segment.
+func decodeIPv4(s *segment, data []byte) ([]byte, bool) {
+	if len(data) < 20 || data[0]>>4 != 4 {
+		return nil, false
+	}
+	headerLen := int(data[0]&0x0f) * 4
+	totalLen := int(binary.BigEndian.Uint16(data[2:4]))
+	if headerLen < 20 || totalLen < headerLen || len(data) < headerLen {
+		return nil, false
+	}
+	// ignore fragments other than unfragmented packets
+	if fragment := binary.BigEndian.Uint16(data[6:8]); fragment&0x3fff != 0 {
+		return nil, false
+	}
+	if data[9] != ipProtocolTCP {
+		return nil, false
+	}
+	s.src = net.IP(data[12:16]).String()
+	s.dst = net.IP(data[16:20]).String()
+	// trim any link-layer padding, allowing for TCP segmentation offload
+	// captures where the total length is zero
+	if totalLen != 0 && totalLen < len(data) {
+		data = data[:totalLen]
+	}
+	return data[headerLen:], true
+}
+
+// decodeIPv6 fills in the addresses of the segment and returns the IPv6
+// payload if it carries an unfragmented TCP segment.
+func decodeIPv6(s *segment, data []byte) ([]byte, bool) {
+	if len(data) < 40 || data[0]>>4 != 6 {
+		return nil, false
+	}
+	payloadLen := int(binary.BigEndian.Uint16(data[4:6]))
+	nextHeader := data[6]
+	s.src = net.IP(data[8:24]).String()
+	s.dst = net.IP(data[24:40]).String()
+	data = data[40:]
+	if payloadLen != 0 && payloadLen < len(data) {
+		data = data[:payloadLen]
+	}
+	for {
+		switch nextHeader {
+		case ipProtocolTCP:
+			return data, true
+		case ipv6HopByHop, ipv6Routing, ipv6DestOpts:
+			if len(data) < 8 {
+				return nil, false
+			}
+			extLen := (int(data[1]) + 1) * 8
+			if len(data) < extLen {
+				return nil, false
+			}
+			nextHeader = data[0]
+			data = data[extLen:]
+		default: // fragments and other protocols are not supported
+			return nil, false
+		}
+	}
+}
+
+// decodeTCPHeader fills in the ports, sequence number, flags, and payload of
+// the segment.
+func decodeTCPHeader(s *segment, data []byte) bool {
+	if len(data) < 20 {
+		return false
+	}
+	headerLen := int(data[12]>>4) * 4
+	if headerLen < 20 || len(data) < headerLen {
+		return false
+	}
+	s.srcPort = binary.BigEndian.Uint16(data[0:2])
+	s.dstPort = binary.BigEndian.Uint16(data[2:4])
+	s.seq = binary.BigEndian.Uint32(data[4:8])
+	s.flags = data[13]
+	s.payload = data[headerLen:]
+	return true
+}
+
+// srcAddr returns the source address and port of the segment.
+func (s segment) srcAddr() string {
+	return net.JoinHostPort(s.src, strconv.Itoa(int(s.srcPort)))
+}
+
+// dstAddr returns the destination address and port of the segment.
+func (s segment) dstAddr() string {
+	return net.JoinHostPort(s.dst, strconv.Itoa(int(s.dstPort)))
+}
@@ -0,0 +1,57 @@
+package capture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Classic pcap file constants.
+// Source:
+//  - https://wiki.wireshark.org/Development/LibpcapFileFormat
+const (
+	pcapMagicMicro       uint32 = 0xa1b2c3d4
+	pcapMagicNano        uint32 = 0xa1b23c4d
+	pcapFileHeaderLen    int    = 24
+	pcapRecordHeaderLen  int    = 16
+	maxCapturedPacketLen uint32 = 256 * 1024
+)
+
+// A pcapReader reads packets from a classic pcap file.
+type pcapReader struct {
+	input     io.Reader
+	byteOrder binary.ByteOrder
+	linkType  uint32
+	header    [pcapRecordHeaderLen]byte
+}
+
+// newPcapReader reads the pcap file header and returns a reader for the
+// packets that follow it.
+func newPcapReader(input io.Reader) (*pcapReader, error) {
+	var header [pcapFileHeaderLen]byte
+	if _, err := io.ReadFull(input, header[:]); err != nil {
+		return nil, fmt.Errorf("pcap: short file header")
+	}
+	a := &pcapReader{input: input, byteOrder: binary.LittleEndian}
+	if magic := binary.BigEndian.Uint32(header[:4]); magic == pcapMagicMicro || magic == pcapMagicNano {
+		a.byteOrder = binary.BigEndian
+	}
+	a.linkType = a.byteOrder.Uint32(header[20:24]) & 0x0fffffff // upper bits may hold FCS info
+	return a, nil
+}
+
+// next returns the link type and data of the next packet.
+func (a *pcapReader) next() (uint32, []byte, error) {
+	if _, err := io.ReadFull(a.input, a.header[:]); err != nil {
+		return 0, nil, err
+	}
+	capturedLen := a.byteOrder.Uint32(a.header[8:12])
+	if capturedLen > maxCapturedPacketLen {
+		return 0, nil, fmt.Errorf("pcap: packet length %d too large", capturedLen)
+	}
+	data := make([]byte, capturedLen)
+	if _, err := io.ReadFull(a.input, data); err != nil {
+		return 0, nil, err
+	}
+	return a.linkType, data, nil
+}
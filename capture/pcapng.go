@@ -0,0 +1,138 @@
+package capture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Pcapng block types and constants.
+// Source:
+//  - https://tools.ietf.org/html/draft-tuexen-opsawg-pcapng-02
+const (
+	pcapngTypeSHB        uint32 = 0x0a0d0d0a
+	pcapngTypeIDB        uint32 = 0x00000001
+	pcapngTypePacket     uint32 = 0x00000002 // obsolete packet block
+	pcapngTypeSPB        uint32 = 0x00000003
+	pcapngTypeEPB        uint32 = 0x00000006
+	pcapngByteOrderMagic uint32 = 0x1a2b3c4d
+	pcapngBlockHeaderLen int    = 8
+	maxPcapngBlockLen    uint32 = 16 * 1024 * 1024
+)
+
+var pcapngBlockTypeSHB = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+
+// A pcapngInterface describes a capture interface declared in an interface
+// description block.
+type pcapngInterface struct {
+	linkType uint32
+	snapLen  uint32
+}
+
+// A pcapngReader reads packets from a pcapng file, which may contain multiple
+// sections and interfaces.
+type pcapngReader struct {
+	input      io.Reader
+	byteOrder  binary.ByteOrder
+	interfaces []pcapngInterface
+}
+
+// newPcapngReader returns a reader for the pcapng blocks in input.
+func newPcapngReader(input io.Reader) *pcapngReader {
+	return &pcapngReader{input: input, byteOrder: binary.LittleEndian}
+}
+
+// next returns the link type and data of the next packet, skipping blocks that
+// do not contain packets.
+func (a *pcapngReader) next() (uint32, []byte, error) {
+	for {
+		blockType, body, err := a.readBlock()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch blockType {
+		case pcapngTypeIDB:
+			if len(body) < 8 {
+				return 0, nil, fmt.Errorf("pcapng: short interface description block")
+			}
+			a.interfaces = append(a.interfaces, pcapngInterface{
+				linkType: uint32(a.byteOrder.Uint16(body[0:2])),
+				snapLen:  a.byteOrder.Uint32(body[4:8]),
+			})
+		case pcapngTypeEPB, pcapngTypePacket:
+			if len(body) < 20 {
+				return 0, nil, fmt.Errorf("pcapng: short packet block")
+			}
+			var ifaceID uint32
+			if blockType == pcapngTypeEPB {
+				ifaceID = a.byteOrder.Uint32(body[0:4])
+			} else {
+				ifaceID = uint32(a.byteOrder.Uint16(body[0:2]))
+			}
+			capturedLen := a.byteOrder.Uint32(body[12:16])
+			if int(ifaceID) >= len(a.interfaces) || uint64(capturedLen) > uint64(len(body)-20) {
+				return 0, nil, fmt.Errorf("pcapng: malformed packet block")
+			}
+			return a.interfaces[ifaceID].linkType, body[20 : 20+capturedLen], nil
+		case pcapngTypeSPB:
+			if len(body) < 4 || len(a.interfaces) == 0 {
+				return 0, nil, fmt.Errorf("pcapng: malformed simple packet block")
+			}
+			// the captured length is the smaller of the original length and
+			// the snap length of the first interface
+			capturedLen := a.byteOrder.Uint32(body[0:4])
+			if snapLen := a.interfaces[0].snapLen; snapLen != 0 && snapLen < capturedLen {
+				capturedLen = snapLen
+			}
+			if uint64(capturedLen) > uint64(len(body)-4) {
+				capturedLen = uint32(len(body) - 4)
+			}
+			return a.interfaces[0].linkType, body[4 : 4+capturedLen], nil
+		}
+	}
+}
+
+// readBlock reads the next block and returns its type and body, excluding the
+// block header and trailing length.
+func (a *pcapngReader) readBlock() (uint32, []byte, error) {
+	var header [pcapngBlockHeaderLen + 4]byte
+	if _, err := io.ReadFull(a.input, header[:pcapngBlockHeaderLen]); err != nil {
+		return 0, nil, err
+	}
+	if binary.BigEndian.Uint32(header[0:4]) == pcapngTypeSHB {
+		// a section header block starts a new section which may change the
+		// byte order, so read the byte-order magic before the block length
+		if _, err := io.ReadFull(a.input, header[pcapngBlockHeaderLen:]); err != nil {
+			return 0, nil, err
+		}
+		switch pcapngByteOrderMagic {
+		case binary.LittleEndian.Uint32(header[8:12]):
+			a.byteOrder = binary.LittleEndian
+		case binary.BigEndian.Uint32(header[8:12]):
+			a.byteOrder = binary.BigEndian
+		default:
+			return 0, nil, fmt.Errorf("pcapng: bad byte-order magic")
+		}
+		a.interfaces = nil
+		blockLen := a.byteOrder.Uint32(header[4:8])
+		if blockLen < 28 || blockLen > maxPcapngBlockLen || blockLen%4 != 0 {
+			return 0, nil, fmt.Errorf("pcapng: bad section header block length %d", blockLen)
+		}
+		rest := make([]byte, blockLen-uint32(len(header)))
+		if _, err := io.ReadFull(a.input, rest); err != nil {
+			return 0, nil, err
+		}
+		return pcapngTypeSHB, rest, nil
+	}
+	blockType := a.byteOrder.Uint32(header[0:4])
+	blockLen := a.byteOrder.Uint32(header[4:8])
+	if blockLen < 12 || blockLen > maxPcapngBlockLen || blockLen%4 != 0 {
+		return 0, nil, fmt.Errorf("pcapng: bad block length %d", blockLen)
+	}
+	rest := make([]byte, blockLen-uint32(pcapngBlockHeaderLen))
+	if _, err := io.ReadFull(a.input, rest); err != nil {
+		return 0, nil, err
+	}
+	// drop the trailing copy of the block length
+	return blockType, rest[:len(rest)-4], nil
+}
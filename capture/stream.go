@@ -0,0 +1,272 @@
+package capture
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TLS record content types.
+// Source:
+//  - https://tools.ietf.org/html/rfc5246#section-6.2.1
+const (
+	recordTypeChangeCipherSpec uint8 = 0x14
+	recordTypeAlert            uint8 = 0x15
+	recordTypeHandshake        uint8 = 0x16
+	recordHeaderLen            int   = 5
+	sslv2RecordHeaderLen       int   = 2
+	handshakeHeaderLen         int   = 4
+	handshakeTypeClientHello   uint8 = 0x01
+)
+
+var httpMethods = map[string]bool{
+	"CONNECT": true,
+	"DELETE":  true,
+	"GET":     true,
+	"HEAD":    true,
+	"OPTIONS": true,
+	"PATCH":   true,
+	"POST":    true,
+	"PUT":     true,
+	"TRACE":   true,
+}
+
+// A streamSegment is a piece of data sent in one direction of a connection.
+type streamSegment struct {
+	seq     uint32
+	payload []byte
+}
+
+// A halfStream collects the data sent in one direction of a connection.
+type halfStream struct {
+	isn      uint32
+	isnKnown bool
+	segments []streamSegment
+}
+
+// add records the data carried by a segment.
+func (a *halfStream) add(s segment) {
+	seq := s.seq
+	if s.flags&tcpFlagSyn != 0 {
+		// the syn consumes one sequence number
+		seq++
+		a.isn = seq
+		a.isnKnown = true
+	}
+	if len(s.payload) > 0 {
+		a.segments = append(a.segments, streamSegment{seq: seq, payload: s.payload})
+	}
+}
+
+// bytes reassembles the data sent in this direction, up to the first gap in
+// the sequence space.
+func (a *halfStream) bytes() []byte {
+	if len(a.segments) == 0 {
+		return nil
+	}
+	base := a.isn
+	if !a.isnKnown {
+		// without a syn, start at the lowest sequence number seen, allowing
+		// for wraparound relative to the first segment
+		first := a.segments[0].seq
+		minOffset := int32(0)
+		for _, elem := range a.segments {
+			if offset := int32(elem.seq - first); offset < minOffset {
+				minOffset = offset
+			}
+		}
+		base = first + uint32(minOffset)
+	}
+	segments := make([]streamSegment, len(a.segments))
+	copy(segments, a.segments)
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].seq-base < segments[j].seq-base })
+
+	var data []byte
+	var cursor uint32
+	for _, elem := range segments {
+		start := elem.seq - base
+		end := start + uint32(len(elem.payload))
+		if int32(start) < 0 || start > cursor {
+			break // data before the stream start or after a gap
+		}
+		if end <= cursor {
+			continue // retransmission
+		}
+		data = append(data, elem.payload[cursor-start:]...)
+		cursor = end
+	}
+	return data
+}
+
+// A connection tracks both directions of a TCP connection.
+type connection struct {
+	client   string
+	server   string
+	toServer halfStream
+	toClient halfStream
+}
+
+// An assembler groups TCP segments into connections.
+type assembler struct {
+	connections map[string]*connection
+	order       []*connection
+}
+
+// add assigns a segment to its connection, creating the connection if needed.
+func (a *assembler) add(s segment) {
+	if a.connections == nil {
+		a.connections = make(map[string]*connection)
+	}
+	src, dst := s.srcAddr(), s.dstAddr()
+	key := src + " " + dst
+	if dst < src {
+		key = dst + " " + src
+	}
+	isSyn := s.flags&tcpFlagSyn != 0 && s.flags&tcpFlagAck == 0
+	conn, ok := a.connections[key]
+	if ok && isSyn && conn.client == src && (conn.toServer.isnKnown || len(conn.toServer.segments) > 0) {
+		// a new syn after data or an earlier syn reuses the port pair for a
+		// new connection, unless it is a retransmission of the same syn
+		if !conn.toServer.isnKnown || conn.toServer.isn != s.seq+1 {
+			ok = false
+		}
+	}
+	if !ok {
+		conn = &connection{client: src, server: dst}
+		switch {
+		case isSyn:
+		case s.flags&tcpFlagSyn != 0: // syn-ack from the server
+			conn.client, conn.server = dst, src
+		case s.dstPort > s.srcPort:
+			// guess that the server uses the lower (well-known) port
+			conn.client, conn.server = dst, src
+		}
+		a.connections[key] = conn
+		a.order = append(a.order, conn)
+	}
+	if src == conn.client {
+		conn.toServer.add(s)
+	} else {
+		conn.toClient.add(s)
+	}
+}
+
+// flows returns the client hellos and http requests of each connection.
+func (a *assembler) flows() []Flow {
+	var flows []Flow
+	for _, conn := range a.order {
+		flow := Flow{Client: conn.client, Server: conn.server}
+		data := conn.toServer.bytes()
+		switch {
+		case len(data) >= recordHeaderLen && data[0] == recordTypeHandshake && data[1] == 0x03:
+			flow.ClientHellos = parseTLSStream(data)
+		case len(data) > sslv2RecordHeaderLen && data[0]&0x80 != 0 && data[2] == handshakeTypeClientHello:
+			flow.ClientHellos = parseSSLv2Stream(data)
+		case isHTTPRequest(data):
+			flow.HTTPRequests = parseHTTPStream(data)
+		}
+		flows = append(flows, flow)
+	}
+	return flows
+}
+
+// parseTLSStream returns the client hello messages sent at the start of a
+// client's TLS stream, stopping once the stream is encrypted.
+func parseTLSStream(data []byte) [][]byte {
+	var hellos [][]byte
+	var handshake []byte
+	afterChangeCipherSpec := false
+	for len(data) >= recordHeaderLen && data[1] == 0x03 {
+		recordType := data[0]
+		length := int(data[3])<<8 | int(data[4])
+		if len(data) < recordHeaderLen+length {
+			break // truncated record
+		}
+		payload := data[recordHeaderLen : recordHeaderLen+length]
+		data = data[recordHeaderLen+length:]
+		switch recordType {
+		case recordTypeHandshake:
+			if afterChangeCipherSpec && len(handshake) == 0 && !isClientHelloRecord(payload) {
+				// encrypted handshake message
+				continue
+			}
+			handshake = append(handshake, payload...)
+			for len(handshake) >= handshakeHeaderLen {
+				msgLen := handshakeHeaderLen + (int(handshake[1])<<16 | int(handshake[2])<<8 | int(handshake[3]))
+				if len(handshake) < msgLen {
+					break
+				}
+				if handshake[0] == handshakeTypeClientHello {
+					hello := make([]byte, msgLen)
+					copy(hello, handshake)
+					hellos = append(hellos, hello)
+				}
+				handshake = handshake[msgLen:]
+			}
+		case recordTypeChangeCipherSpec:
+			// TLS 1.3 clients may send a change cipher spec before a second
+			// client hello in response to a hello retry request
+			afterChangeCipherSpec = true
+			handshake = nil
+		case recordTypeAlert:
+		default: // application data
+			return hellos
+		}
+	}
+	return hellos
+}
+
+// parseSSLv2Stream returns the SSL 2.0 compatible client hello record sent at
+// the start of a client's stream. Clients send at most one such record, and
+// switch to TLS records if the server replies with a later version.
+func parseSSLv2Stream(data []byte) [][]byte {
+	length := sslv2RecordHeaderLen + (int(data[0]&0x7f)<<8 | int(data[1]))
+	if len(data) < length {
+		return nil // truncated record
+	}
+	hello := make([]byte, length)
+	copy(hello, data)
+	return [][]byte{hello}
+}
+
+// isClientHelloRecord returns true if the record payload holds exactly one
+// complete client hello message.
+func isClientHelloRecord(payload []byte) bool {
+	if len(payload) < handshakeHeaderLen || payload[0] != handshakeTypeClientHello {
+		return false
+	}
+	msgLen := int(payload[1])<<16 | int(payload[2])<<8 | int(payload[3])
+	return handshakeHeaderLen+msgLen == len(payload)
+}
+
+// isHTTPRequest returns true if data starts with an HTTP request method.
+func isHTTPRequest(data []byte) bool {
+	idx := bytes.IndexByte(data, ' ')
+	return idx > 0 && httpMethods[string(data[:idx])]
+}
+
+// parseHTTPStream returns the HTTP requests sent on a cleartext stream,
+// skipping request bodies with a known content length.
+func parseHTTPStream(data []byte) []HTTPRequest {
+	var requests []HTTPRequest
+	for isHTTPRequest(data) {
+		end := bytes.Index(data, []byte("\r\n\r\n"))
+		if end == -1 {
+			break // incomplete request header
+		}
+		lines := strings.Split(string(data[:end]), "\r\n")
+		data = data[end+4:]
+		request := HTTPRequest{RequestLine: lines[0], HeaderLines: lines[1:]}
+		requests = append(requests, request)
+		if contentLength, err := strconv.Atoi(request.Header("Content-Length")); err == nil && contentLength > 0 {
+			if contentLength > len(data) {
+				break
+			}
+			data = data[contentLength:]
+		} else if strings.EqualFold(request.Header("Transfer-Encoding"), "chunked") {
+			break // chunked bodies are not followed
+		}
+	}
+	return requests
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/avct/uasurfer"
+	"github.com/cloudflare/mitmengine/capture"
+	"github.com/cloudflare/mitmengine/db"
+	fp "github.com/cloudflare/mitmengine/fputil"
+)
+
+const (
+	uaHeader   = "<browser_name>:<browser_version>:<os_platform>:<os_name>:<os_version>:<device_type>:<quirks>"
+	reqHeader  = "<tls_version>:<cipher_suites>:<extension_names>:<curves>:<ec_point_fmts>:<http_headers>:<quirks>[:<sig_algs>[:<alpn_protocols>[:<supported_versions>[:<key_shares>]]]]"
+	mitmHeader = "<mitm_name>:<mitm_type>:<mitm_grade>"
+
+	// defaultOutDir is separate from reference_fingerprints/mitmengine, since
+	// the generated records are only a partial regeneration of the reference
+	// records and must not replace them.
+	defaultOutDir = "buildref_output"
+)
+
+// A metadata entry describes a fingerprint sample, as documented in the
+// README.
+type metadata struct {
+	Desc          string `json:"desc"`
+	Comment       string `json:"comment"`
+	HandshakePcap string `json:"handshake_pcap"`
+	HeaderJSON    string `json:"header_json"`
+	HeaderPcap    string `json:"header_pcap"`
+	UAFingerprint *struct {
+		RawUA          string `json:"raw_ua"`
+		Browser        string `json:"browser"`
+		BrowserVersion string `json:"browser_version"`
+		OS             string `json:"os"`
+		OSVersion      string `json:"os_version"`
+		Platform       string `json:"platform"`
+		Device         string `json:"device"`
+	} `json:"ua_fingerprint"`
+	MitmFingerprint *struct {
+		RawUA string `json:"raw_ua"`
+		Name  string `json:"name"`
+		Type  string `json:"type"`
+		Grade string `json:"grade"`
+	} `json:"mitm_fingerprint"`
+}
+
+// Names used in the metadata file, mapped to the constants defined in
+// uasurfer and fputil.
+var (
+	browserNames  = uaNames("Browser", func(i int) fmt.Stringer { return uasurfer.BrowserName(i) })
+	osNames       = uaNames("OS", func(i int) fmt.Stringer { return uasurfer.OSName(i) })
+	platformNames = uaNames("Platform", func(i int) fmt.Stringer { return uasurfer.Platform(i) })
+	deviceNames   = uaNames("Device", func(i int) fmt.Stringer { return uasurfer.DeviceType(i) })
+	mitmTypes     = map[string]uint8{
+		"":            fp.TypeEmpty,
+		"Antivirus":   fp.TypeAntivirus,
+		"FakeBrowser": fp.TypeFakeBrowser,
+		"Malware":     fp.TypeMalware,
+		"Parental":    fp.TypeParental,
+		"Proxy":       fp.TypeProxy,
+	}
+	// default platform for an OS if the metadata does not specify one
+	osPlatforms = map[string]string{
+		"Windows": "Windows",
+		"MacOSX":  "Mac",
+		"Android": "Linux",
+		"Linux":   "Linux",
+	}
+)
+
+// uaNames returns a map from uasurfer constant names, without the prefix, to
+// their integer values.
+func uaNames(prefix string, name func(int) fmt.Stringer) map[string]int {
+	names := make(map[string]int)
+	for i := 0; ; i++ {
+		s := name(i).String()
+		if !strings.HasPrefix(s, prefix) || strings.Contains(s, "(") {
+			break
+		}
+		names[strings.TrimPrefix(s, prefix)] = i
+	}
+	return names
+}
+
+// Additional mitm records based on injected http headers and quirks, which
+// cannot be derived from the reference pcaps.
+// Sources:
+//  - https://jhalderm.com/pub/papers/interception-ndss17.pdf
+//  - https://github.com/zakird/tlsfingerprints/blob/master/processing/browsers/browser.py#L131
+var headerMitmRecords = []string{
+	"# add some additional records based on injected http headers",
+	"0::0:0::0:|:*:*:*:*:*barracuda:*|Barracuda:5:0",
+	"0::0:0::0:|:*:*:*:*:*cuda_cliip:*|Barracuda:5:0",
+	"0::0:0::0:|:*:*:*:*:*gdata-version:*|GData:1:4",
+	"0::0:0::0:|:*:*:*:*:*gdataver:*|GData:1:4",
+	"0::0:0::0:|:*:*:*:*:*pxyro-connection:*|Citrix:5:0",
+	"0::0:0::0:|:*:*:*:*:*squixa-proxy:*|Squixa:0:0",
+	"0::0:0::0:|:*:*:*:*:*x-akamai-config-log-detail:*|Akamai:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-akamai-edgescape:*|Akamai:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-akamai-origin-hop:*|Akamai:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-akamai-prefetched-object:*|Akamai:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-barracuda-wf-agent:*|Barracuda:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-barracuda-wf-app:*|Barracuda:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-barracuda-wf-device:*|Barracuda:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-barracuda-wf-deviceid:*|Barracuda:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-barracuda-wf-domain-dns:*|Barracuda:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-barracuda-wf-domain:*|Barracuda:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-barracuda-wf-machine:*|Barracuda:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-barracuda-wf-os:*|Barracuda:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-barracuda-wf-user:*|Barracuda:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-bluecoat-user:*|BlueCoat:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-bluecoat-via:*|BlueCoat:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-citrix-am-credentialtypes:*|Citrix:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-citrix-am-labeltypes:*|Citrix:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-citrix-gateway:*|Citrix:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-citrix-via-vip:*|Citrix:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-citrix-via:*|Citrix:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-cybersitter-content-flag:*|Cybersitter:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-cybersitter-csvt-token:*|Cybersitter:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-cybersitter-oemid:*|Cybersitter:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-drweb-keynumber:*|DrWeb:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-drweb-matchate:*|DrWeb:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-drweb-syshash:*|DrWeb:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-eset-spread-control:*|ESET:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-eset-updateid:*|ESET:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-fcckv2:*|Fortinet:1:0",
+	"0::0:0::0:|:*:*:*:*:*x-gdata-device:*|GData:1:4",
+	"0::0:0::0:|:*:*:*:*:*x-netnanny-ignore:*|NetNanny:4:0",
+	"0::0:0::0:|:*:*:*:*:*x-nod32-mode:*|ESET:5:0",
+	"0::0:0::0:|:*:*:*:*:*x-sophos-filter:*|Sophos:1:0",
+	"0::0:0::0:|:*:*:*:*:*x-sophos-meta:*|Sophos:1:0",
+	"0::0:0::0:|:*:*:*:*:*x-sophos-wsa-clientip:*|Sophos:1:0",
+	"0::0:0::0:|:*:*:*:*:*x-websensehost:*|Forcepoint/WebSense:0:0",
+	"0::0:0::0:|:*:*:*:*:*x-websenseproxychannel:*|Forcepoint/WebSense:0:0",
+	"0::0:0::0:|:*:*:*:*:*x-websenseproxysslconnection:*|Forcepoint/WebSense:0:0",
+	"0::0:0::0:|:*:*:*:*:*x_bluecoat_user:*|BlueCoat:5:0",
+	"0::0:0::0:|:*:*:*:*:*x_bluecoat_via:*|BlueCoat:5:0",
+	"0::0:0::0:|:*:*:*:*:*xroxy-connection:*|Kerio-Winroute-Firewall:0:0",
+	"0::0:0::0:|:*:*:*:*:*z-forwarded-for:*|Zscaler:0:0",
+	"0::0:0::0:|:*:*:25,24,23:*:*client-ip,x-forwarded-for:*|Forcepoint/WebSense:5:0",
+	"# add signatures based on quirks that none of the supported browsers should ever have",
+	"0::0:0::0:|:*:*:*:*:*:*badhost|:0:0",
+	"0::0:0::0:|:*:*:*:*:*:*badcase|:0:0",
+	"0::0:0::0:|:*:*:*:*:*:*badpath|:0:0",
+	"0::0:0::0:|:*:*:*:*:*:*badspace|:0:0",
+	"0::0:0::0:|:*:*:*:*:*:*badreferer|:0:0",
+	"0::0:0::0:|:*:*:*:*:*:*badxff|:0:0",
+	"0::0:0::0:|:*:*:*:*:*:*badhdr|:0:0",
+}
+
+func main() {
+	metadataFileName := flag.String("metadata", filepath.Join("reference_fingerprints", "fingerprint_metadata.jsonl"), "File containing fingerprint sample metadata")
+	rootDir := flag.String("root", ".", "Directory that sample paths in the metadata file are relative to")
+	badHeaderFileName := flag.String("badheader", filepath.Join("reference_fingerprints", "mitmengine", "badheader.txt"), "File containing non-browser (bad) HTTP headers")
+	browserFileName := flag.String("browser", filepath.Join(defaultOutDir, "browser.txt"), "Output file for browser records")
+	mitmFileName := flag.String("mitm", filepath.Join(defaultOutDir, "mitm.txt"), "Output file for mitm records")
+	flag.Parse()
+
+	badHeaderSet, err := loadBadHeaders(*badHeaderFileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	entries, err := loadMetadata(*metadataFileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var browserDatabase, mitmDatabase db.Database
+	for _, entry := range entries {
+		// samples without a user agent or mitm label cannot be used as records
+		isMitm := entry.MitmFingerprint != nil && len(entry.MitmFingerprint.Name) > 0 && entry.MitmFingerprint.Name != "none"
+		if !isMitm && entry.UAFingerprint == nil {
+			continue
+		}
+		record, err := buildRecord(*rootDir, entry, badHeaderSet)
+		if err != nil {
+			log.Printf("WARNING: skipping sample \"%s\": %s", entry.Desc, err)
+			continue
+		}
+		if isMitm {
+			mitmDatabase.Add(record)
+		} else {
+			browserDatabase.Add(record)
+		}
+	}
+
+	if err := writeDatabase(*browserFileName, browserDatabase, nil); err != nil {
+		log.Fatal(err)
+	}
+	if err := writeDatabase(*mitmFileName, mitmDatabase, headerMitmRecords); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Wrote %d browser records to %s and %d mitm records to %s\n", browserDatabase.Len(), *browserFileName, mitmDatabase.Len(), *mitmFileName)
+}
+
+// loadMetadata reads the metadata entries from a JSON lines file.
+func loadMetadata(fileName string) ([]metadata, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var entries []metadata
+	decoder := json.NewDecoder(file)
+	for {
+		var entry metadata
+		if err := decoder.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %s", fileName, err)
+		}
+		if mitm := entry.MitmFingerprint; mitm != nil {
+			if _, ok := mitmTypes[mitm.Type]; !ok {
+				return nil, fmt.Errorf("%s: sample \"%s\": unknown mitm type \"%s\"", fileName, entry.Desc, mitm.Type)
+			}
+			if _, err := fp.NewGrade(mitm.Grade); err != nil {
+				return nil, fmt.Errorf("%s: sample \"%s\": %s", fileName, entry.Desc, err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// loadBadHeaders reads the set of headers that browsers never send.
+func loadBadHeaders(fileName string) (fp.StringSet, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var badHeaderList fp.StringList
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		badHeaderList = append(badHeaderList, scanner.Text())
+	}
+	return badHeaderList.Set(), scanner.Err()
+}
+
+// buildRecord builds a record from the pcaps and labels of a sample.
+func buildRecord(rootDir string, entry metadata, badHeaderSet fp.StringSet) (db.Record, error) {
+	var record db.Record
+	requestFingerprint, err := readRequestFingerprint(filepath.Join(rootDir, entry.HandshakePcap))
+	if err != nil {
+		return record, err
+	}
+	var headerLines []string
+	switch {
+	case len(entry.HeaderJSON) > 0:
+		headerLines, err = readHeaderJSON(filepath.Join(rootDir, entry.HeaderJSON))
+	case len(entry.HeaderPcap) > 0:
+		headerLines, err = readHeaderPcap(filepath.Join(rootDir, entry.HeaderPcap))
+	}
+	if err != nil {
+		return record, err
+	}
+	request := capture.HTTPRequest{HeaderLines: headerLines}
+	requestFingerprint.Header = request.HeaderNames()
+	requestFingerprint = requestFingerprint.Normalize(badHeaderSet)
+	if record.RequestSignature, err = fp.NewRequestSignature(requestFingerprint.String()); err != nil {
+		return record, err
+	}
+
+	if record.UASignature, err = buildUASignature(entry); err != nil {
+		return record, err
+	}
+	if entry.MitmFingerprint != nil && len(entry.MitmFingerprint.Name) > 0 && entry.MitmFingerprint.Name != "none" {
+		record.MitmInfo.NameList = fp.StringList{entry.MitmFingerprint.Name}
+		record.MitmInfo.Type = mitmTypes[entry.MitmFingerprint.Type]
+		// the metadata is validated by loadMetadata
+		record.MitmInfo.Grade, _ = fp.NewGrade(entry.MitmFingerprint.Grade)
+		if record.MitmInfo.Grade == fp.GradeEmpty {
+			record.MitmInfo.Grade = requestGrade(requestFingerprint)
+		}
+	}
+	return record, nil
+}
+
+// requestGrade returns the security grade of a request fingerprint, merging
+// the grades of its version and cipher suites with its security assessment,
+// as Processor.Check does for the actual grade of a request.
+func requestGrade(requestFingerprint fp.RequestFingerprint) fp.Grade {
+	check := fp.GlobalCipherCheck
+	grade := requestFingerprint.Version.Grade().Merge(check.Grade(requestFingerprint.Cipher))
+	return grade.Merge(check.Assess(requestFingerprint).Grade)
+}
+
+// readRequestFingerprint returns the fingerprint of the last client hello in
+// a pcap.
+func readRequestFingerprint(fileName string) (fp.RequestFingerprint, error) {
+	flows, err := capture.ReadFile(fileName)
+	if err != nil {
+		return fp.RequestFingerprint{}, err
+	}
+	clientHello := capture.LastClientHello(flows)
+	if clientHello == nil {
+		return fp.RequestFingerprint{}, fmt.Errorf("%s: no TLS Client Hello found", fileName)
+	}
+	return fp.ParseClientHello(clientHello)
+}
+
+// readHeaderJSON returns the header lines of the first HTTP request in a
+// TShark JSON file.
+func readHeaderJSON(fileName string) ([]string, error) {
+	jsonStr, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var decoded []struct {
+		Source struct {
+			Layers struct {
+				RequestLines []string `json:"http.request.line"`
+			} `json:"layers"`
+		} `json:"_source"`
+	}
+	if err = json.Unmarshal(jsonStr, &decoded); err != nil {
+		return nil, fmt.Errorf("%s: %s", fileName, err)
+	}
+	for _, pkt := range decoded {
+		if len(pkt.Source.Layers.RequestLines) == 0 {
+			continue
+		}
+		var headerLines []string
+		for _, requestLine := range pkt.Source.Layers.RequestLines {
+			headerLines = append(headerLines, strings.TrimSpace(requestLine))
+		}
+		return headerLines, nil
+	}
+	return nil, nil
+}
+
+// readHeaderPcap returns the header lines of the first cleartext HTTP request
+// in a pcap.
+func readHeaderPcap(fileName string) ([]string, error) {
+	flows, err := capture.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	for _, flow := range flows {
+		if len(flow.HTTPRequests) > 0 {
+			return flow.HTTPRequests[0].HeaderLines, nil
+		}
+	}
+	return nil, nil
+}
+
+// buildUASignature returns the user agent signature for a sample, taken from
+// the metadata labels.
+func buildUASignature(entry metadata) (fp.UASignature, error) {
+	if entry.UAFingerprint == nil {
+		return fp.NewUASignature("0::0:0::0:")
+	}
+	ua := entry.UAFingerprint
+	platform := ua.Platform
+	if len(platform) == 0 {
+		platform = osPlatforms[ua.OS]
+	}
+	fields := []string{
+		fmt.Sprint(browserNames[ua.Browser]),
+		ua.BrowserVersion,
+		fmt.Sprint(platformNames[platform]),
+		fmt.Sprint(osNames[ua.OS]),
+		ua.OSVersion,
+		fmt.Sprint(deviceNames[ua.Device]),
+		"",
+	}
+	return fp.NewUASignature(strings.Join(fields, ":"))
+}
+
+// writeDatabase writes the sorted, de-duplicated records of a database to a
+// file, followed by any extra lines.
+func writeDatabase(fileName string, database db.Database, extra []string) error {
+	var lines []string
+	seen := make(map[string]bool)
+	for _, record := range database.Records {
+		line := record.String()
+		if !seen[line] {
+			seen[line] = true
+			lines = append(lines, line)
+		}
+	}
+	sort.Strings(lines)
+
+	if err := os.MkdirAll(filepath.Dir(fileName), 0777); err != nil {
+		return err
+	}
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	output := bufio.NewWriter(file)
+	fmt.Fprintln(output, "# generated by cmd/buildref")
+	fmt.Fprintf(output, "# %s|%s|%s\n", uaHeader, reqHeader, mitmHeader)
+	for _, line := range append(lines, extra...) {
+		fmt.Fprintln(output, line)
+	}
+	if err := output.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
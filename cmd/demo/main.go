@@ -1,17 +1,20 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
-	"os/exec"
+	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/avct/uasurfer"
 	"github.com/cloudflare/mitmengine"
+	"github.com/cloudflare/mitmengine/capture"
 	fp "github.com/cloudflare/mitmengine/fputil"
 )
 
@@ -20,33 +23,117 @@ func main() {
 	mitmFileName := flag.String("mitm", filepath.Join("reference_fingerprints", "mitmengine", "mitm.txt"), "File containing mitm signatures")
 	badHeaderFileName := flag.String("badheader", filepath.Join("reference_fingerprints", "mitmengine", "badheader.txt"), "File containing non-browser (bad) HTTP headers")
 	handshakePcapFileName := flag.String("handshake", filepath.Join("reference_fingerprints", "pcaps", "misc", "ios5", "handshake.pcap"), "Pcap containing TLS Client Hello")
+	ja3String := flag.String("ja3", "", "JA3 string to use instead of the TLS Client Hello in the handshake pcap")
+	ja3FileName := flag.String("ja3file", "", "File containing one JA3 string per line, optionally followed by a tab and a User Agent, to check in a batch (- for stdin)")
 	headerJsonFileName := flag.String("header", filepath.Join("reference_fingerprints", "pcaps", "middleboxes", "barracuda", "barracuda-chrome48", "header.json"), "Json file containing HTTP headers")
+	registryFileName := flag.String("registry", "", "Json file containing cipher suites, named groups, extensions, and signature schemes to add to the default registry")
 	flag.Parse()
 
 	// Load config
 	var err error
-	mitmProcessor, err := mitmengine.NewProcessor(&mitmengine.Config{
+	config := mitmengine.Config{
 		BrowserFileName:   *browserFileName,
 		MitmFileName:      *mitmFileName,
 		BadHeaderFileName: *badHeaderFileName,
-	})
+	}
+	if len(*registryFileName) > 0 {
+		registryFile, err := os.Open(*registryFileName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		registry := fp.DefaultRegistry()
+		err = registry.ReadJSON(registryFile)
+		registryFile.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		cipherCheck := fp.NewCipherCheckFromRegistry(registry)
+		config.CipherCheck = &cipherCheck
+	}
+	mitmProcessor, err := mitmengine.NewProcessor(&config)
 
 	if err != nil {
 		log.Fatal(err)
 	}
-	// Read in TLS Client Hello fingerprint
-	requestFingerprintString, err := exec.Command(filepath.Join("scripts", "pcap_to_request_fingerprint.py"), *handshakePcapFileName).Output()
+	// Read in HTTP request fingerprint
+	rawUa, err := readUserAgent(*headerJsonFileName)
 	if err != nil {
 		log.Fatal(err)
 	}
-	requestFingerprint, err := fp.NewRequestFingerprint(strings.TrimSpace(string(requestFingerprintString)))
-	if err != nil {
-		log.Fatal(err)
+	if len(*ja3FileName) > 0 {
+		input := os.Stdin
+		if *ja3FileName != "-" {
+			if input, err = os.Open(*ja3FileName); err != nil {
+				log.Fatal(err)
+			}
+			defer input.Close()
+		}
+		if err := checkJA3Lines(&mitmProcessor, input, rawUa); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	// Read in HTTP request fingerprint
-	jsonStr, err := ioutil.ReadFile(*headerJsonFileName)
+	// Read in TLS Client Hello fingerprint, either from a JA3 string or using
+	// the last Client Hello in the pcap
+	var requestFingerprint fp.RequestFingerprint
+	if len(*ja3String) > 0 {
+		requestFingerprint, err = fp.NewRequestFingerprintFromJA3(*ja3String)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		flows, err := capture.ReadFile(*handshakePcapFileName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		clientHello := capture.LastClientHello(flows)
+		if clientHello == nil {
+			log.Fatalf("%s: no TLS Client Hello found", *handshakePcapFileName)
+		}
+		requestFingerprint, err = fp.ParseClientHello(clientHello)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	uaFingerprint := newUAFingerprint(rawUa)
+	report := mitmProcessor.Check(uaFingerprint, rawUa, requestFingerprint)
+
+	// Print out human-readable report
+	fmt.Printf("Request fingerprint JA4: %s\n", report.JA4)
+	if report.Error != nil {
+		fmt.Printf("MITM results inconclusive: %v\n", report.Error)
+		return
+	}
+	fmt.Printf("User agent fingerprint matched signature from database:\n\tua fp:\t%v\n\tua sig:\t%v\n", uaFingerprint, report.MatchedUASignature)
+	fmt.Printf("Expect request fingerprint to match request signature from database:\n\trq fp:\t%v\n\trq sig:\t%v\n\tmatch:\t%v", requestFingerprint, report.BrowserSignature, report.BrowserSignatureMatch)
+	if report.BrowserSignatureMatch == fp.MatchPossible {
+		fmt.Printf("\n")
+	} else {
+		fmt.Printf("\n\treason:\t%v\n", report.Reason)
+		for _, finding := range report.Findings {
+			fmt.Printf("\t%s:\t%v (missing: %v, extra: %v, excluded: %v, unlikely: %v, out of order: %v)\n", finding.Field, finding.Match, finding.Missing, finding.Extra, finding.Excluded, finding.Unlikely, finding.OutOfOrder)
+		}
+	}
+	fmt.Printf("Security report:\n\tbrowser grade:\t%v\n\tactual grade:\t%v\n\tsecurity grade:\t%v\n\tweak ciphers:\t%v\n\tloses pfs:\t%v\n\tdowngrade:\t%v\n", report.BrowserGrade, report.ActualGrade, report.Security.Grade, report.WeakCiphers, report.LosesPfs, report.VersionDowngrade)
+	for _, finding := range report.Security.Findings {
+		fmt.Printf("\t%s:\t%v %v (grade %v)\n", finding.Name, finding.Field, finding.Values.String(), finding.Grade)
+	}
+	for _, factor := range report.ActualGradeExplanation.Factors {
+		fmt.Printf("\tactual grade factor %s:\t%v (grade %v)\n", factor.Name, factor.Value, factor.Grade)
+	}
+	if len(report.MatchedMitmSignature) > 0 {
+		fmt.Printf("Request fingerprint matched known MITM signature:\n\trq sig:\t%v\n\tname:\t%v\n\ttype:\t%v\n", report.MatchedMitmSignature, report.MatchedMitmName, report.MatchedMitmType)
+	} else {
+		fmt.Printf("Request fingerprint did not match any known MITM signatures\n")
+	}
+}
+
+// readUserAgent returns the User Agent of the first HTTP request in a TShark
+// JSON file that has one.
+func readUserAgent(fileName string) (string, error) {
+	jsonStr, err := ioutil.ReadFile(fileName)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	var decoded []struct {
 		Source struct {
@@ -56,23 +143,23 @@ func main() {
 		} `json:"_source"`
 	}
 	if err = json.Unmarshal(jsonStr, &decoded); err != nil {
-		log.Fatal(err)
+		return "", err
 	}
-	rawUa := ""
 	for _, pkt := range decoded {
 		for _, requestLine := range pkt.Source.Layers.RequestLines {
 			if strings.Contains(requestLine, "User-Agent:") {
-				rawUa = strings.TrimSpace(strings.TrimPrefix(requestLine, "User-Agent:"))
-				break
+				return strings.TrimSpace(strings.TrimPrefix(requestLine, "User-Agent:")), nil
 			}
 		}
-		if len(rawUa) > 0 {
-			break
-		}
 	}
-	// Convert uasurfer.UserAgent to fp.UAFingerprint
+	return "", nil
+}
+
+// newUAFingerprint converts the uasurfer.UserAgent parsed from a raw User
+// Agent to a fp.UAFingerprint.
+func newUAFingerprint(rawUa string) fp.UAFingerprint {
 	ua := uasurfer.Parse(rawUa)
-	uaFingerprint := fp.UAFingerprint{
+	return fp.UAFingerprint{
 		BrowserName:    int(ua.Browser.Name),
 		BrowserVersion: fp.UAVersion(ua.Browser.Version),
 		OSPlatform:     int(ua.OS.Platform),
@@ -80,24 +167,34 @@ func main() {
 		OSVersion:      fp.UAVersion(ua.OS.Version),
 		DeviceType:     int(ua.DeviceType),
 	}
-	report := mitmProcessor.Check(uaFingerprint, rawUa, requestFingerprint)
+}
 
-	// Print out human-readable report
-	if report.Error != nil {
-		fmt.Printf("MITM results inconclusive: %v\n", report.Error)
-		return
-	}
-	fmt.Printf("User agent fingerprint matched signature from database:\n\tua fp:\t%v\n\tua sig:\t%v\n", uaFingerprint, report.MatchedUASignature)
-	fmt.Printf("Expect request fingerprint to match request signature from database:\n\trq fp:\t%v\n\trq sig:\t%v\n\tmatch:\t%v", requestFingerprint, report.BrowserSignature, report.BrowserSignatureMatch)
-	if report.BrowserSignatureMatch == fp.MatchPossible {
-		fmt.Printf("\n")
-	} else {
-		fmt.Printf("\n\treason:\t%v\n", report.Reason)
-	}
-	fmt.Printf("Security report:\n\tbrowser grade:\t%v\n\tactual grade:\t%v\n\tweak ciphers:\t%v\n\tloses pfs:\t%v\n", report.BrowserGrade, report.ActualGrade, report.WeakCiphers, report.LosesPfs)
-	if len(report.MatchedMitmSignature) > 0 {
-		fmt.Printf("Request fingerprint matched known MITM signature:\n\trq sig:\t%v\n\tname:\t%v\n\ttype:\t%v\n", report.MatchedMitmSignature, report.MatchedMitmName, report.MatchedMitmType)
-	} else {
-		fmt.Printf("Request fingerprint did not match any known MITM signatures\n")
+// checkJA3Lines checks each JA3 string read from the input, one per line, and
+// prints a tab-separated summary line for each. A line may follow the JA3
+// string with a tab and the User Agent of the request, which otherwise
+// defaults to rawUa. Empty lines and lines starting with '#' are skipped.
+func checkJA3Lines(mitmProcessor *mitmengine.Processor, input io.Reader, rawUa string) error {
+	fmt.Println("# ja3\tmatch\tbrowser grade\tactual grade\tmitm name\terror")
+	scanner := bufio.NewScanner(input)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		ja3String, lineUa := line, rawUa
+		if idx := strings.IndexByte(line, '\t'); idx != -1 {
+			ja3String, lineUa = line[:idx], strings.TrimSpace(line[idx+1:])
+		}
+		requestFingerprint, err := fp.NewRequestFingerprintFromJA3(ja3String)
+		if err != nil {
+			return fmt.Errorf("line %d: %s", lineNum, err)
+		}
+		report := mitmProcessor.Check(newUAFingerprint(lineUa), lineUa, requestFingerprint)
+		var reportErr string
+		if report.Error != nil {
+			reportErr = report.Error.Error()
+		}
+		fmt.Printf("%s\t%v\t%v\t%v\t%s\t%s\n", ja3String, report.BrowserSignatureMatch, report.BrowserGrade, report.ActualGrade, report.MatchedMitmName, reportErr)
 	}
+	return scanner.Err()
 }
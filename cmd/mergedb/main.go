@@ -37,16 +37,26 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	// Merge records in fresh databases, since the databases of a snapshot may
+	// be shared and must not be modified.
+	snapshot := mitmProcessor.Snapshot()
+	var browserDatabase, mitmDatabase db.Database
+	for _, record := range snapshot.BrowserDatabase.Records {
+		browserDatabase.Add(record)
+	}
+	for _, record := range snapshot.MitmDatabase.Records {
+		mitmDatabase.Add(record)
+	}
 	os.MkdirAll(outDir, 0777)
 
 	scanner := bufio.NewScanner(os.Stdin)
 
 	var before, after int
 	if askUser(scanner, "Automatically merge browser database?") {
-		size := len(mitmProcessor.BrowserDatabase.Records)
+		size := len(browserDatabase.Records)
 		total := size * size
 		count := 0
-		before, after = mitmProcessor.BrowserDatabase.MergeBy(func(r1, r2 db.Record) bool {
+		before, after = browserDatabase.MergeBy(func(r1, r2 db.Record) bool {
 			count++
 			if count%size == 0 {
 				fmt.Printf("(%.2f)\r", (float32(count*100))/float32(total))
@@ -80,7 +90,7 @@ func main() {
 		fmt.Printf("Before: %d, After: %d\n", before, after)
 	}
 	if askUser(scanner, "Manually merge browser database?") {
-		before, after = mitmProcessor.BrowserDatabase.MergeBy(func(r1, r2 db.Record) bool {
+		before, after = browserDatabase.MergeBy(func(r1, r2 db.Record) bool {
 			return askUser(scanner, fmt.Sprintf("in1: %s\nin2: %s\nout: %s\nMerge? ", r1, r2, r1.Merge(r2)))
 		})
 		fmt.Printf("Before: %d, After: %d\n", before, after)
@@ -91,17 +101,17 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	mitmProcessor.BrowserDatabase.Dump(file)
+	browserDatabase.Dump(file)
 
 	if askUser(scanner, "Automatically merge mitm database?") {
-		mitmProcessor.MitmDatabase.MergeBy(func(r1, r2 db.Record) bool { return r1.RequestSignature.String() == r2.RequestSignature.String() })
-		mitmProcessor.MitmDatabase.MergeBy(func(r1, r2 db.Record) bool {
+		mitmDatabase.MergeBy(func(r1, r2 db.Record) bool { return r1.RequestSignature.String() == r2.RequestSignature.String() })
+		mitmDatabase.MergeBy(func(r1, r2 db.Record) bool {
 			return r1.RequestSignature.String() == r2.RequestSignature.String()
 		})
 	}
 	if askUser(scanner, "Manually merge mitm database?") {
-		mitmProcessor.MitmDatabase.MergeBy(func(r1, r2 db.Record) bool { return r1.RequestSignature.String() == r2.RequestSignature.String() })
-		mitmProcessor.MitmDatabase.MergeBy(func(r1, r2 db.Record) bool {
+		mitmDatabase.MergeBy(func(r1, r2 db.Record) bool { return r1.RequestSignature.String() == r2.RequestSignature.String() })
+		mitmDatabase.MergeBy(func(r1, r2 db.Record) bool {
 			return askUser(scanner, fmt.Sprintf("in1: %s\nin2: %s\nout: %s\nMerge? ", r1, r2, r1.Merge(r2)))
 		})
 	}
@@ -111,6 +121,6 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	mitmProcessor.MitmDatabase.Dump(file)
+	mitmDatabase.Dump(file)
 	fmt.Println("Finished")
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudflare/mitmengine/loader"
+)
+
+// Sign a bundle of fingerprint files for loading with loader.Verified. The
+// private key file contains the base64-encoded ed25519 private key, and the
+// public key to configure loader.NewVerified with is printed.
+func main() {
+	dir := flag.String("dir", filepath.Join("reference_fingerprints", "mitmengine"), "Directory containing the fingerprint files")
+	files := flag.String("files", "browser.txt,mitm.txt,badheader.txt", "Comma-separated list of files in the bundle")
+	keyFileName := flag.String("key", "bundle.key", "File containing the base64-encoded ed25519 private key")
+	generateKey := flag.Bool("genkey", false, "Generate a new private key and write it to the key file")
+	flag.Parse()
+
+	var privateKey ed25519.PrivateKey
+	if *generateKey {
+		var err error
+		if _, privateKey, err = ed25519.GenerateKey(rand.Reader); err != nil {
+			log.Fatal(err)
+		}
+		if err := ioutil.WriteFile(*keyFileName, []byte(base64.StdEncoding.EncodeToString(privateKey)+"\n"), 0600); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		keyData, err := ioutil.ReadFile(*keyFileName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(keyData)))
+		if err != nil || len(key) != ed25519.PrivateKeySize {
+			log.Fatalf("invalid private key in %s", *keyFileName)
+		}
+		privateKey = ed25519.PrivateKey(key)
+	}
+
+	contents := make(map[string][]byte)
+	for _, fileName := range strings.Split(*files, ",") {
+		data, err := ioutil.ReadFile(filepath.Join(*dir, fileName))
+		if err != nil {
+			log.Fatal(err)
+		}
+		contents[fileName] = data
+	}
+	manifest := loader.NewManifest(contents).Bytes()
+	if err := ioutil.WriteFile(filepath.Join(*dir, loader.ManifestFileName), manifest, 0644); err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(*dir, loader.SignatureFileName), loader.SignManifest(manifest, privateKey), 0644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Public key: %s\n", base64.StdEncoding.EncodeToString(privateKey.Public().(ed25519.PublicKey)))
+}
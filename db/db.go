@@ -12,6 +12,10 @@ import (
 // A Database contains a collection of records containing software signatures.
 type Database struct {
 	Records []Record
+
+	// non-exported fields
+	uaIndex      *uaIndex
+	requestIndex *requestIndex
 }
 
 // NewDatabase returns a new Database initialized from the configuration.
@@ -47,9 +51,20 @@ func (a *Database) Load(input io.Reader) error {
 		}
 		a.Add(record)
 	}
+	a.BuildIndex()
 	return nil
 }
 
+// BuildIndex indexes the records for GetByUAFingerprint and
+// GetByRequestFingerprint. Load builds the index, and Add, Clear, DeleteBy,
+// and MergeBy drop it, so it only needs to be called after modifying records
+// through those methods or after modifying Records directly. Lookups fall back
+// to matching every record if there is no index.
+func (a *Database) BuildIndex() {
+	a.uaIndex = newUAIndex(a.Records)
+	a.requestIndex = newRequestIndex(a.Records)
+}
+
 // Len returns the length of the database
 func (a *Database) Len() int {
 	return len(a.Records)
@@ -57,12 +72,14 @@ func (a *Database) Len() int {
 
 // Add a single record to the database.
 func (a *Database) Add(record Record) int {
+	a.uaIndex, a.requestIndex = nil, nil
 	a.Records = append(a.Records, record)
 	return len(a.Records)
 }
 
 // Clear all records from the database.
 func (a *Database) Clear() {
+	a.uaIndex, a.requestIndex = nil, nil
 	a.Records = []Record{}
 }
 
@@ -80,16 +97,36 @@ func (a Database) Dump(output io.Writer) error {
 // GetByRequestFingerprint returns all records in the database matching the
 // request fingerprint.
 func (a Database) GetByRequestFingerprint(requestFingerprint fp.RequestFingerprint) []int {
-	return a.GetBy(func(r Record) bool {
+	getFunc := func(r Record) bool {
 		match, _ := r.RequestSignature.Match(requestFingerprint)
 		return match != fp.MatchImpossible
-	})
+	}
+	if a.requestIndex == nil || a.requestIndex.size != len(a.Records) {
+		return a.GetBy(getFunc)
+	}
+	var recordIds []int
+	for _, id := range a.requestIndex.candidates(requestFingerprint) {
+		if getFunc(a.Records[id]) {
+			recordIds = append(recordIds, id)
+		}
+	}
+	return recordIds
 }
 
 // GetByUAFingerprint returns all records in the database matching the
 // user agent fingerprint.
 func (a Database) GetByUAFingerprint(uaFingerprint fp.UAFingerprint) []int {
-	return a.GetBy(func(r Record) bool { return r.UASignature.Match(uaFingerprint) != fp.MatchImpossible })
+	getFunc := func(r Record) bool { return r.UASignature.Match(uaFingerprint) != fp.MatchImpossible }
+	if a.uaIndex == nil || a.uaIndex.size != len(a.Records) {
+		return a.GetBy(getFunc)
+	}
+	var recordIds []int
+	for _, id := range a.uaIndex.candidates(uaFingerprint) {
+		if getFunc(a.Records[id]) {
+			recordIds = append(recordIds, id)
+		}
+	}
+	return recordIds
 }
 
 // GetBy returns a list of records for which GetBy returns true.
@@ -105,6 +142,7 @@ func (a Database) GetBy(getFunc func(Record) bool) []int {
 
 // DeleteBy deletes records for which rmFunc returns true.
 func (a *Database) DeleteBy(deleteFunc func(Record) bool) {
+	a.uaIndex, a.requestIndex = nil, nil
 	recordIds := a.GetBy(deleteFunc)
 	for _, id := range recordIds {
 		a.Records = append(a.Records[:id], a.Records[id+1:]...)
@@ -113,6 +151,7 @@ func (a *Database) DeleteBy(deleteFunc func(Record) bool) {
 
 // MergeBy merges records for which mergeFunc returns true.
 func (a *Database) MergeBy(mergeFunc func(Record, Record) bool) (int, int) {
+	a.uaIndex, a.requestIndex = nil, nil
 	before := len(a.Records)
 	for id1 := 0; id1 < len(a.Records); id1++ {
 		for id2 := 0; id2 < len(a.Records); id2++ {
@@ -2,6 +2,7 @@ package db_test
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/cloudflare/mitmengine/db"
@@ -20,6 +21,28 @@ func TestDatabaseLoad(t *testing.T) {
 	testutil.Ok(t, err)
 }
 
+func TestDatabaseLoadDraftVersion(t *testing.T) {
+	records := "1:0:0:0:0:0:|303,7f1c,304::2b:::::::~304,7f1c,?fafa|:0:0\n" +
+		"1:0:0:0:0:0:|7f17::::::|:0:0\n"
+	a, err := db.NewDatabase(strings.NewReader(records))
+	testutil.Ok(t, err)
+	testutil.Equals(t, 2, a.Len())
+	var tests = []struct {
+		in  string
+		out []int
+	}{
+		{"7f1c::2b:::::::fafa,7f1c,304", []int{0}},
+		{"302::2b:::::::fafa,7f1c,304", []int(nil)},
+		{"303::2b:::::::fafa,7f1a,304", []int(nil)},
+		{"7f17::::::", []int{1}},
+	}
+	for _, test := range tests {
+		fingerprint, err := fp.NewRequestFingerprint(test.in)
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.out, a.GetByRequestFingerprint(fingerprint))
+	}
+}
+
 func TestDatabaseAdd(t *testing.T) {
 	a, _ := db.NewDatabase(bytes.NewReader(nil))
 	testutil.Equals(t, 0, a.Len())
@@ -0,0 +1,275 @@
+package db
+
+import (
+	"math"
+	"sort"
+
+	fp "github.com/cloudflare/mitmengine/fputil"
+)
+
+// A uaIndex finds the records whose user agent signatures can match a user
+// agent fingerprint without matching against every record. Records are
+// grouped by the browser name, OS name, and device type of their user agent
+// signatures, where zero is a wildcard, and each group is split into buckets
+// of browser major versions.
+type uaIndex struct {
+	size   int
+	groups map[uaKey]*versionBuckets
+}
+
+// A uaKey identifies a group of records in a uaIndex.
+type uaKey struct {
+	browserName int
+	osName      int
+	deviceType  int
+}
+
+// versionBuckets splits the browser major versions into consecutive ranges,
+// where bucket i holds the ids of the records that can match any version from
+// bounds[i] up to but not including bounds[i+1], in increasing order.
+type versionBuckets struct {
+	bounds  []int
+	buckets [][]int
+}
+
+// newUAIndex returns an index over the records.
+func newUAIndex(records []Record) *uaIndex {
+	ids := make(map[uaKey][]int)
+	for id, record := range records {
+		key := uaKey{record.UASignature.BrowserName, record.UASignature.OSName, record.UASignature.DeviceType}
+		ids[key] = append(ids[key], id)
+	}
+	a := &uaIndex{size: len(records), groups: make(map[uaKey]*versionBuckets, len(ids))}
+	for key, groupIds := range ids {
+		a.groups[key] = newVersionBuckets(records, groupIds)
+	}
+	return a
+}
+
+// newVersionBuckets returns the version buckets for a group of records.
+func newVersionBuckets(records []Record, ids []int) *versionBuckets {
+	var a versionBuckets
+	seen := make(map[int]bool)
+	for _, id := range ids {
+		min, max := records[id].UASignature.BrowserVersion.MajorBounds()
+		seen[min] = true
+		if max != math.MaxInt32 {
+			seen[max+1] = true
+		}
+	}
+	for bound := range seen {
+		a.bounds = append(a.bounds, bound)
+	}
+	sort.Ints(a.bounds)
+	a.buckets = make([][]int, len(a.bounds))
+	for _, id := range ids {
+		min, max := records[id].UASignature.BrowserVersion.MajorBounds()
+		for idx, bound := range a.bounds {
+			if min <= bound && bound <= max {
+				a.buckets[idx] = append(a.buckets[idx], id)
+			}
+		}
+	}
+	return &a
+}
+
+// get returns the ids of the records in the bucket containing the major
+// version.
+func (a *versionBuckets) get(major int) []int {
+	idx := sort.SearchInts(a.bounds, major+1) - 1
+	if idx < 0 {
+		return nil
+	}
+	return a.buckets[idx]
+}
+
+// candidates returns the ids, in increasing order, of the records whose user
+// agent signatures have browser names, OS names, device types, and browser
+// versions that can match the fingerprint. The remaining fields of the
+// signatures still need to be matched.
+func (a *uaIndex) candidates(uaFingerprint fp.UAFingerprint) []int {
+	var recordIds []int
+	for _, browserName := range wildcardKeys(uaFingerprint.BrowserName) {
+		for _, osName := range wildcardKeys(uaFingerprint.OSName) {
+			for _, deviceType := range wildcardKeys(uaFingerprint.DeviceType) {
+				if buckets, ok := a.groups[uaKey{browserName, osName, deviceType}]; ok {
+					recordIds = append(recordIds, buckets.get(uaFingerprint.BrowserVersion.Major)...)
+				}
+			}
+		}
+	}
+	sort.Ints(recordIds)
+	return recordIds
+}
+
+// wildcardKeys returns the signature values that match a fingerprint value.
+func wildcardKeys(value int) []int {
+	if value == 0 {
+		return []int{0}
+	}
+	return []int{value, 0}
+}
+
+// A requestIndex finds the records whose request signatures can match a
+// request fingerprint without fully matching against every record. Records
+// whose cipher signatures allow only a single ordered list of ciphers are
+// bucketed by the hash of that list, and the remaining records are filtered
+// with bitsets of the values that a matching fingerprint must have and the
+// values that it can have.
+type requestIndex struct {
+	size     int
+	exact    map[uint64][]int
+	wildcard []requestMask
+}
+
+// A requestMask records the cipher, extension, curve, and header values of a
+// request signature in fixed-size bitsets, where each value sets the bit given
+// by its low byte or, for headers, by the low byte of its hash.
+type requestMask struct {
+	id        int
+	cipher    intMask
+	extension intMask
+	curve     intMask
+	header    bitset
+}
+
+// An intMask records the values of an int signature. A list can only match
+// the signature if the required bits are all set in the list's bitset and the
+// list's bits are all set in the allowed bitset.
+type intMask struct {
+	required bitset
+	allowed  bitset
+}
+
+// A bitset is a set of bytes.
+type bitset [4]uint64
+
+// fullBitset contains every byte.
+var fullBitset = bitset{^uint64(0), ^uint64(0), ^uint64(0), ^uint64(0)}
+
+// newRequestIndex returns an index over the records.
+func newRequestIndex(records []Record) *requestIndex {
+	a := &requestIndex{size: len(records), exact: make(map[uint64][]int)}
+	for id, record := range records {
+		signature := record.RequestSignature
+		if isExactSignature(signature.Cipher) {
+			hash := hashIntList(signature.Cipher.OrderedList)
+			a.exact[hash] = append(a.exact[hash], id)
+			continue
+		}
+		var header bitset
+		for elem := range signature.Header.RequiredSet {
+			header.insert(hashString(elem))
+		}
+		a.wildcard = append(a.wildcard, requestMask{
+			id:        id,
+			cipher:    newIntMask(signature.Cipher),
+			extension: newIntMask(signature.Extension),
+			curve:     newIntMask(signature.Curve),
+			header:    header,
+		})
+	}
+	return a
+}
+
+// candidates returns the ids, in increasing order, of the records that pass
+// the prefilter for the fingerprint. The signatures of these records still
+// need to be matched.
+func (a *requestIndex) candidates(requestFingerprint fp.RequestFingerprint) []int {
+	exactIds := a.exact[hashIntList(requestFingerprint.Cipher)]
+	recordIds := make([]int, len(exactIds), len(exactIds)+len(a.wildcard))
+	copy(recordIds, exactIds)
+	cipher := newBitset(requestFingerprint.Cipher)
+	extension := newBitset(requestFingerprint.Extension)
+	curve := newBitset(requestFingerprint.Curve)
+	var header bitset
+	for _, elem := range requestFingerprint.Header {
+		header.insert(hashString(elem))
+	}
+	for _, mask := range a.wildcard {
+		if mask.cipher.match(cipher) && mask.extension.match(extension) && mask.curve.match(curve) && mask.header.subsetOf(header) {
+			recordIds = append(recordIds, mask.id)
+		}
+	}
+	sort.Ints(recordIds)
+	return recordIds
+}
+
+// newIntMask returns the mask for an int signature. Any value is allowed if
+// the signature does not have an ordered list.
+func newIntMask(signature fp.IntSignature) intMask {
+	a := intMask{required: newBitset(signature.RequiredSet.List()), allowed: fullBitset}
+	if signature.OrderedList != nil {
+		a.allowed = newBitset(signature.OrderedList)
+	}
+	return a
+}
+
+// match returns false if a list with the bitset cannot match the signature.
+func (a intMask) match(b bitset) bool {
+	return a.required.subsetOf(b) && b.subsetOf(a.allowed)
+}
+
+// isExactSignature returns true if the only list that can match the int
+// signature is its ordered list, which is the case when every element of the
+// ordered list is required and appears once.
+func isExactSignature(signature fp.IntSignature) bool {
+	if signature.OrderedList == nil || len(signature.OrderedList) != signature.RequiredSet.Len() {
+		return false
+	}
+	for _, elem := range signature.OrderedList {
+		if !signature.RequiredSet.Has(elem) {
+			return false
+		}
+	}
+	return true
+}
+
+// hashIntList returns the 64-bit FNV-1a hash of the list.
+func hashIntList(list fp.IntList) uint64 {
+	hash := uint64(fnvOffset64)
+	for _, elem := range list {
+		hash = (hash ^ uint64(byte(elem>>8))) * fnvPrime64
+		hash = (hash ^ uint64(byte(elem))) * fnvPrime64
+	}
+	return hash
+}
+
+// hashString returns the low byte of the 64-bit FNV-1a hash of the string.
+func hashString(s string) int {
+	hash := uint64(fnvOffset64)
+	for idx := 0; idx < len(s); idx++ {
+		hash = (hash ^ uint64(s[idx])) * fnvPrime64
+	}
+	return int(byte(hash))
+}
+
+const (
+	fnvOffset64 uint64 = 14695981039346656037
+	fnvPrime64  uint64 = 1099511628211
+)
+
+// newBitset returns a bitset containing the low byte of each element of the
+// list.
+func newBitset(list fp.IntList) bitset {
+	var a bitset
+	for _, elem := range list {
+		a.insert(elem)
+	}
+	return a
+}
+
+// insert adds the low byte of the element to the bitset.
+func (a *bitset) insert(elem int) {
+	a[(elem&0xff)>>6] |= 1 << uint(elem&0x3f)
+}
+
+// subsetOf returns true if every element of a is in b.
+func (a bitset) subsetOf(b bitset) bool {
+	for idx := range a {
+		if a[idx]&^b[idx] != 0 {
+			return false
+		}
+	}
+	return true
+}
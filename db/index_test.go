@@ -0,0 +1,271 @@
+package db_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudflare/mitmengine/db"
+	fp "github.com/cloudflare/mitmengine/fputil"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+// linearGetByUAFingerprint returns the records matching the user agent
+// fingerprint by matching against every record.
+func linearGetByUAFingerprint(a db.Database, uaFingerprint fp.UAFingerprint) []int {
+	return a.GetBy(func(r db.Record) bool { return r.UASignature.Match(uaFingerprint) != fp.MatchImpossible })
+}
+
+// uaFingerprintsNear returns user agent fingerprints on and around the
+// boundaries of a user agent signature.
+func uaFingerprintsNear(signature fp.UASignature) []fp.UAFingerprint {
+	var majors []int
+	for _, major := range []int{signature.BrowserVersion.Min.Major, signature.BrowserVersion.Max.Major} {
+		majors = append(majors, major-1, major, major+1)
+	}
+	var fingerprints []fp.UAFingerprint
+	for _, browserName := range []int{0, signature.BrowserName, signature.BrowserName + 1} {
+		for _, osName := range []int{0, signature.OSName} {
+			for _, deviceType := range []int{0, signature.DeviceType} {
+				for _, major := range majors {
+					fingerprints = append(fingerprints, fp.UAFingerprint{
+						BrowserName:    browserName,
+						BrowserVersion: fp.UAVersion{Major: major, Minor: 0, Patch: 0},
+						OSPlatform:     signature.OSPlatform,
+						OSName:         osName,
+						OSVersion:      signature.OSVersion.Min,
+						DeviceType:     deviceType,
+					})
+				}
+			}
+		}
+	}
+	return fingerprints
+}
+
+func TestDatabaseGetByUAFingerprintIndex(t *testing.T) {
+	for _, fileName := range []string{"browser.txt", "mitm.txt"} {
+		file, err := os.Open(filepath.Join("..", "reference_fingerprints", "mitmengine", fileName))
+		testutil.Ok(t, err)
+		a, err := db.NewDatabase(file)
+		file.Close()
+		testutil.Ok(t, err)
+		testutil.Assert(t, a.Len() > 0, "no records in %s", fileName)
+		seen := make(map[string]bool)
+		for _, record := range a.Records {
+			for _, uaFingerprint := range uaFingerprintsNear(record.UASignature) {
+				if seen[uaFingerprint.String()] {
+					continue
+				}
+				seen[uaFingerprint.String()] = true
+				expected := linearGetByUAFingerprint(a, uaFingerprint)
+				testutil.Equals(t, expected, a.GetByUAFingerprint(uaFingerprint))
+			}
+		}
+	}
+}
+
+func TestDatabaseGetByUAFingerprintIndexBounds(t *testing.T) {
+	var records = []string{
+		"1::0:0::0:|::::::|:0:0",
+		"1:10-20:0:0::0:|::::::|:0:0",
+		"1:15-:0:0::0:|::::::|:0:0",
+		"1:-12:0:0::0:|::::::|:0:0",
+		"1:30:0:2::0:|::::::|:0:0",
+		"0:5-6:0:0::3:|::::::|:0:0",
+		"0::0:0::0:|::::::|:0:0",
+		"2:18-18:1:2:10:1:|::::::|:0:0",
+	}
+	var a db.Database
+	for _, recordString := range records {
+		var record db.Record
+		testutil.Ok(t, record.Parse(recordString))
+		a.Add(record)
+	}
+	var fingerprints []fp.UAFingerprint
+	for _, browserName := range []int{0, 1, 2, 3} {
+		for _, osName := range []int{0, 2} {
+			for _, deviceType := range []int{0, 1, 3} {
+				for _, major := range []int{-1, 0, 4, 5, 6, 7, 9, 10, 11, 12, 13, 14, 15, 18, 20, 21, 30, 31, math.MaxInt32} {
+					fingerprints = append(fingerprints, fp.UAFingerprint{
+						BrowserName:    browserName,
+						BrowserVersion: fp.UAVersion{Major: major, Minor: -1, Patch: -1},
+						OSPlatform:     1,
+						OSName:         osName,
+						OSVersion:      fp.UAVersion{Major: 10, Minor: -1, Patch: -1},
+						DeviceType:     deviceType,
+					})
+				}
+			}
+		}
+	}
+	// without an index, lookups match against every record
+	for _, uaFingerprint := range fingerprints {
+		testutil.Equals(t, linearGetByUAFingerprint(a, uaFingerprint), a.GetByUAFingerprint(uaFingerprint))
+	}
+	a.BuildIndex()
+	for _, uaFingerprint := range fingerprints {
+		testutil.Equals(t, linearGetByUAFingerprint(a, uaFingerprint), a.GetByUAFingerprint(uaFingerprint))
+	}
+	// adding a record drops the index
+	var record db.Record
+	testutil.Ok(t, record.Parse("3::0:0::0:|::::::|:0:0"))
+	a.Add(record)
+	for _, uaFingerprint := range fingerprints {
+		testutil.Equals(t, linearGetByUAFingerprint(a, uaFingerprint), a.GetByUAFingerprint(uaFingerprint))
+	}
+}
+
+// linearGetByRequestFingerprint returns the records matching the request
+// fingerprint by matching against every record.
+func linearGetByRequestFingerprint(a db.Database, requestFingerprint fp.RequestFingerprint) []int {
+	return a.GetBy(func(r db.Record) bool {
+		match, _ := r.RequestSignature.Match(requestFingerprint)
+		return match != fp.MatchImpossible
+	})
+}
+
+// requestFingerprintsNear returns request fingerprints with every listed
+// value of a request signature, with only the required values, and with the
+// ciphers reversed.
+func requestFingerprintsNear(signature fp.RequestSignature) []fp.RequestFingerprint {
+	full := fp.RequestFingerprint{
+		Version:    signature.Version.Max,
+		Cipher:     signature.Cipher.OrderedList,
+		Extension:  signature.Extension.OrderedList,
+		Curve:      signature.Curve.OrderedList,
+		EcPointFmt: signature.EcPointFmt.OrderedList,
+		Header:     signature.Header.OrderedList,
+		Quirk:      signature.Quirk.OrderedList,
+	}
+	required := full
+	required.Cipher = signature.Cipher.RequiredSet.List()
+	required.Extension = signature.Extension.RequiredSet.List()
+	required.Curve = signature.Curve.RequiredSet.List()
+	reversed := full
+	reversed.Cipher = nil
+	for idx := len(full.Cipher) - 1; idx >= 0; idx-- {
+		reversed.Cipher = append(reversed.Cipher, full.Cipher[idx])
+	}
+	return []fp.RequestFingerprint{full, required, reversed}
+}
+
+func TestDatabaseGetByRequestFingerprintIndex(t *testing.T) {
+	var databases []db.Database
+	for _, fileName := range []string{"mitm.txt", "browser.txt"} {
+		file, err := os.Open(filepath.Join("..", "reference_fingerprints", "mitmengine", fileName))
+		testutil.Ok(t, err)
+		a, err := db.NewDatabase(file)
+		file.Close()
+		testutil.Ok(t, err)
+		testutil.Assert(t, a.Len() > 0, "no records in %s", fileName)
+		databases = append(databases, a)
+	}
+	a := databases[0]
+	seen := make(map[string]bool)
+	for _, b := range databases {
+		for _, record := range b.Records {
+			for _, requestFingerprint := range requestFingerprintsNear(record.RequestSignature) {
+				if seen[requestFingerprint.String()] {
+					continue
+				}
+				seen[requestFingerprint.String()] = true
+				expected := linearGetByRequestFingerprint(a, requestFingerprint)
+				testutil.Equals(t, expected, a.GetByRequestFingerprint(requestFingerprint))
+			}
+		}
+	}
+}
+
+func TestDatabaseGetByRequestFingerprintIndexPrefilter(t *testing.T) {
+	var records = []string{
+		"0::0:0::0:|303:c02b,c02f:0,a:1d:0::|:0:0",
+		"0::0:0::0:|303:c02b,?c02f:0,a:1d:0::|:0:0",
+		"0::0:0::0:|303:*c02f:*a:*:*:*:*|:0:0",
+		"0::0:0::0:|303:~c02f,c02b:*a,^10:*:*:*:*|:0:0",
+		"0::0:0::0:|303:*:*:*1d,17:*:*:*|:0:0",
+		"0::0:0::0:|303:c02b,c02b:0,a:1d:0::|:0:0",
+		"0::0:0::0:|303:*12b:*:*:*:*:*|:0:0",
+		"0::0:0::0:|303::0,a:1d:0::|:0:0",
+		"0::0:0::0:|303:*:*:*:*:*x-bluecoat-via:*|:0:0",
+	}
+	var fingerprints = []string{
+		"303:c02b,c02f:0,a:1d:0::",
+		"303:c02f,c02b:0,a:1d:0::",
+		"303:c02b:0,a:1d:0::",
+		"303:c02b,c02f:0,a,10:1d,17:0::",
+		"303:c02f:a:1d,17:0::",
+		"303:c02b,c02b:0,a:1d:0::",
+		"303:2b,c02f:0,a:1d:0::",
+		"303::0,a:1d:0::",
+		"303:c02b,c02f:0,a:1d:0:::403",
+		"303:c02b,c02f,9c:0,a:1d:0::",
+		"303:c02b,c02f:0,a:1d:0:x-bluecoat-via,host:",
+		"303:c02b,c02f:0,a:1d:0:host:",
+	}
+	var a db.Database
+	for _, recordString := range records {
+		var record db.Record
+		testutil.Ok(t, record.Parse(recordString))
+		a.Add(record)
+	}
+	a.BuildIndex()
+	for _, fingerprintString := range fingerprints {
+		requestFingerprint, err := fp.NewRequestFingerprint(fingerprintString)
+		testutil.Ok(t, err)
+		testutil.Equals(t, linearGetByRequestFingerprint(a, requestFingerprint), a.GetByRequestFingerprint(requestFingerprint))
+	}
+}
+
+func BenchmarkDatabaseGetByUAFingerprint(b *testing.B) {
+	file, err := os.Open(filepath.Join("..", "reference_fingerprints", "mitmengine", "browser.txt"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	a, err := db.NewDatabase(file)
+	file.Close()
+	if err != nil {
+		b.Fatal(err)
+	}
+	uaFingerprint, err := fp.NewUAFingerprint("1:72.0.3626:2:3:10.14.3:1:")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("Index", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			a.GetByUAFingerprint(uaFingerprint)
+		}
+	})
+	b.Run("Linear", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			linearGetByUAFingerprint(a, uaFingerprint)
+		}
+	})
+}
+
+func BenchmarkDatabaseGetByRequestFingerprint(b *testing.B) {
+	file, err := os.Open(filepath.Join("..", "reference_fingerprints", "mitmengine", "mitm.txt"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	a, err := db.NewDatabase(file)
+	file.Close()
+	if err != nil {
+		b.Fatal(err)
+	}
+	requestFingerprint, err := fp.NewRequestFingerprint("303:c02b,c02f,c02c,c030,cca9,cca8,c013,c014,9c,9d,2f,35,a:0,17,ff01,a,b,23,10,5,d,12,33,2d,2b,1b,15:1d,17,18:0:*:grease")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("Index", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			a.GetByRequestFingerprint(requestFingerprint)
+		}
+	})
+	b.Run("Linear", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			linearGetByRequestFingerprint(a, requestFingerprint)
+		}
+	})
+}
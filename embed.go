@@ -0,0 +1,45 @@
+package mitmengine
+
+import (
+	"embed"
+	"io/fs"
+
+	"github.com/cloudflare/mitmengine/loader"
+)
+
+// embeddedDir is the directory of the embedded fingerprint files.
+const embeddedDir string = "reference_fingerprints/mitmengine"
+
+// embeddedFingerprints contains the reference fingerprint files, so that a
+// processor can be created without any data files.
+//
+//go:embed reference_fingerprints/mitmengine/*.txt
+var embeddedFingerprints embed.FS
+
+// NewEmbeddedLoader returns a Loader that reads the reference browser, mitm,
+// and bad header files embedded in the binary, which are named "browser.txt",
+// "mitm.txt", and "badheader.txt".
+func NewEmbeddedLoader() loader.Loader {
+	fsys, err := fs.Sub(embeddedFingerprints, embeddedDir)
+	if err != nil {
+		panic(err) // the embedded directory always exists
+	}
+	return loader.NewFS(fsys)
+}
+
+// DefaultConfig returns a Config that loads the reference fingerprint files
+// embedded in the binary.
+func DefaultConfig() *Config {
+	return &Config{
+		BrowserFileName:   "browser.txt",
+		MitmFileName:      "mitm.txt",
+		BadHeaderFileName: "badheader.txt",
+		Loader:            NewEmbeddedLoader(),
+	}
+}
+
+// NewDefaultProcessor returns a new Processor initialized from the reference
+// fingerprint files embedded in the binary.
+func NewDefaultProcessor() (Processor, error) {
+	return NewProcessor(DefaultConfig())
+}
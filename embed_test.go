@@ -0,0 +1,50 @@
+package mitmengine_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudflare/mitmengine"
+	fp "github.com/cloudflare/mitmengine/fputil"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+// Check that the embedded files are the reference fingerprint files
+func TestNewEmbeddedLoader(t *testing.T) {
+	embeddedLoader := mitmengine.NewEmbeddedLoader()
+	for _, fileName := range []string{"browser.txt", "mitm.txt", "badheader.txt"} {
+		expected, err := ioutil.ReadFile(filepath.Join("reference_fingerprints", "mitmengine", fileName))
+		testutil.Ok(t, err)
+		file, err := embeddedLoader.LoadFile(fileName)
+		testutil.Ok(t, err)
+		actual, err := ioutil.ReadAll(file)
+		file.Close()
+		testutil.Ok(t, err)
+		testutil.Equals(t, expected, actual)
+	}
+	_, err := embeddedLoader.LoadFile("missing.txt")
+	testutil.Assert(t, err != nil, "expected error for missing file")
+}
+
+func TestNewDefaultProcessor(t *testing.T) {
+	// the default processor loads files that do not exist in the working
+	// directory from the embedded loader
+	config := mitmengine.DefaultConfig()
+	for _, fileName := range []string{config.BrowserFileName, config.MitmFileName, config.BadHeaderFileName} {
+		_, err := os.Stat(fileName)
+		testutil.Assert(t, os.IsNotExist(err), "unexpected file %s in the working directory", fileName)
+	}
+	testutil.Equals(t, mitmengine.NewEmbeddedLoader(), config.Loader)
+
+	a, err := mitmengine.NewDefaultProcessor()
+	testutil.Ok(t, err)
+	uaFingerprint, err := fp.NewUAFingerprint("1:72.0.3626:2:3:10.14.3:1:")
+	testutil.Ok(t, err)
+	fingerprint, err := fp.NewRequestFingerprint("303:dada,1301,1302,1303,c02b,c02f,c02c,c030,cca9,cca8,c013,c014,9c,9d,2f,35,a:aaaa,0,17,ff01,a,b,23,10,5,d,12,33,2d,2b,1b,dada,15:9a9a,1d,17,18:0::")
+	testutil.Ok(t, err)
+	report := a.Check(uaFingerprint, "", fingerprint)
+	testutil.Ok(t, report.Error)
+	testutil.Equals(t, fp.MatchPossible, report.BrowserSignatureMatch)
+}
@@ -0,0 +1,113 @@
+package mitmengine
+
+import (
+	"fmt"
+
+	fp "github.com/cloudflare/mitmengine/fputil"
+)
+
+// requestFields lists the fields of a request fingerprint in the order that
+// findings are reported.
+var requestFields = []string{
+	"version",
+	"cipher",
+	"extension",
+	"curve",
+	"ecpointfmt",
+	"sigalg",
+	"alpn",
+	"supportedversion",
+	"keyshare",
+	"header",
+	"quirk",
+}
+
+// newFindings returns a finding for each field of the request fingerprint
+// that does not match the browser signature.
+func newFindings(signature fp.RequestSignature, fingerprint fp.RequestFingerprint) []Finding {
+	matchMap, _ := signature.MatchMap(fingerprint)
+	var findings []Finding
+	for _, field := range requestFields {
+		if matchMap[field] == fp.MatchPossible {
+			continue
+		}
+		finding := Finding{Field: field, Match: matchMap[field]}
+		switch field {
+		case "version":
+			finding.Expected = signature.Version.String()
+			finding.Actual = fingerprint.Version.String()
+		case "cipher":
+			finding.setInts(signature.Cipher, fingerprint.Cipher)
+		case "extension":
+			finding.setInts(signature.Extension, fingerprint.Extension)
+		case "curve":
+			finding.setInts(signature.Curve, fingerprint.Curve)
+		case "ecpointfmt":
+			finding.setInts(signature.EcPointFmt, fingerprint.EcPointFmt)
+		case "sigalg":
+			finding.setInts(signature.SigAlg, fingerprint.SigAlg)
+		case "alpn":
+			finding.setStrings(signature.ALPN, fingerprint.ALPN)
+		case "supportedversion":
+			finding.setInts(signature.SupportedVersion, fingerprint.SupportedVersion)
+		case "keyshare":
+			finding.setInts(signature.KeyShare, fingerprint.KeyShare)
+		case "header":
+			finding.setStrings(signature.Header, fingerprint.Header)
+		case "quirk":
+			finding.setStrings(signature.Quirk, fingerprint.Quirk)
+		}
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// findingsReason returns the overall match level for the findings, and the
+// reason and reason details for the first impossible finding, or the first
+// unlikely finding if none are impossible.
+func findingsReason(findings []Finding) (fp.Match, string, string) {
+	for _, match := range []fp.Match{fp.MatchImpossible, fp.MatchUnlikely} {
+		for _, finding := range findings {
+			if finding.Match == match {
+				return match, fmt.Sprintf("%s_%s", match, finding.Field), fmt.Sprintf("%s vs %s", finding.Expected, finding.Actual)
+			}
+		}
+	}
+	return fp.MatchPossible, "", ""
+}
+
+// setInts sets the expected and actual values and the mismatched elements of
+// the finding for an int signature and list.
+func (a *Finding) setInts(signature fp.IntSignature, list fp.IntList) {
+	a.Expected = signature.String()
+	a.Actual = list.String()
+	explanation := signature.Explain(list)
+	a.Missing = hexStrings(explanation.Missing)
+	a.Extra = hexStrings(explanation.Extra)
+	a.Excluded = hexStrings(explanation.Excluded)
+	a.Unlikely = hexStrings(explanation.Unlikely)
+	a.OutOfOrder = hexStrings(explanation.OutOfOrder)
+}
+
+// setStrings sets the expected and actual values and the mismatched elements
+// of the finding for a string signature and list.
+func (a *Finding) setStrings(signature fp.StringSignature, list fp.StringList) {
+	a.Expected = signature.String()
+	a.Actual = fmt.Sprintf("%s", list)
+	explanation := signature.Explain(list)
+	a.Missing = explanation.Missing
+	a.Extra = explanation.Extra
+	a.Excluded = explanation.Excluded
+	a.Unlikely = explanation.Unlikely
+	a.OutOfOrder = explanation.OutOfOrder
+}
+
+// hexStrings returns the hex-encoded elements of the list, or nil if the list
+// is empty.
+func hexStrings(list fp.IntList) []string {
+	var hexList []string
+	for _, elem := range list {
+		hexList = append(hexList, fmt.Sprintf("%x", elem))
+	}
+	return hexList
+}
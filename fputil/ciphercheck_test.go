@@ -79,6 +79,30 @@ func TestCipherCheckGrade(t *testing.T) {
 	}
 }
 
+func TestCipherCheckExplainGrade(t *testing.T) {
+	var tests = []struct {
+		in  fp.IntList
+		out []fp.GradeFactor
+	}{
+		{fp.IntList{}, nil},
+		{fp.IntList{0x00FF}, nil},
+		{fp.IntList{0xC02B}, []fp.GradeFactor{{Name: fp.GradeFactorFirstCipher, Value: "c02b", Grade: fp.GradeA}}},
+		{fp.IntList{0x00FF, 0xC02B}, []fp.GradeFactor{{Name: fp.GradeFactorFirstCipher, Value: "c02b", Grade: fp.GradeA}}},
+		{fp.IntList{0xC02B, 0x0004, 0x00FF}, []fp.GradeFactor{{Name: fp.FindingKnownAttackCipher, Value: "4", Grade: fp.GradeC}}},
+		{fp.IntList{0x0004, 0xC02B, 0x0003, 0x0005}, []fp.GradeFactor{
+			{Name: fp.FindingBrokenCipher, Value: "3", Grade: fp.GradeF},
+			{Name: fp.FindingKnownAttackCipher, Value: "4,5", Grade: fp.GradeC},
+		}},
+	}
+
+	check := fp.NewCipherCheck()
+	for _, test := range tests {
+		actual := check.ExplainGrade(test.in)
+		testutil.Equals(t, test.out, actual.Factors)
+		testutil.Equals(t, check.Grade(test.in), actual.Grade)
+	}
+}
+
 func TestCipherCheckIsFirstPfs(t *testing.T) {
 	var tests = []struct {
 		in  fp.IntList
@@ -0,0 +1,355 @@
+package fp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// TLS record and handshake constants used when parsing a client hello.
+// Source:
+//  - https://tools.ietf.org/html/rfc5246#section-6.2.1
+//  - https://tools.ietf.org/html/rfc5246#section-7.4
+const (
+	recordTypeHandshake      uint8 = 0x16
+	handshakeTypeClientHello uint8 = 0x01
+	recordHeaderLen          int   = 5
+	handshakeHeaderLen       int   = 4
+	maxRecordPayloadLen      int   = 1<<14 + 2048
+)
+
+// SSL 2.0 record and cipher spec constants used when parsing an SSL 2.0
+// compatible client hello.
+// Source:
+//  - https://tools.ietf.org/html/rfc5246#appendix-E.2
+//  - https://tools.ietf.org/html/rfc6101#appendix-E
+const (
+	sslv2RecordHeaderLen  int = 2
+	sslv2HelloHeaderLen   int = 9
+	sslv2CipherSpecLen    int = 3
+	sslv2RecordLengthMask int = 0x7fff
+)
+
+// TLS extension types used when parsing a client hello.
+// Source:
+//  - https://www.iana.org/assignments/tls-extensiontype-values/tls-extensiontype-values.xhtml
+const (
+	extensionServerName          uint16 = 0x0000
+	extensionSupportedGroups     uint16 = 0x000a
+	extensionEcPointFormats      uint16 = 0x000b
+	extensionSignatureAlgorithms uint16 = 0x000d
+	extensionALPN                uint16 = 0x0010
+	extensionSupportedVersions   uint16 = 0x002b
+	extensionKeyShare            uint16 = 0x0033
+)
+
+var (
+	// ErrorShortClientHello indicates that the input ended before a complete
+	// client hello could be read.
+	ErrorShortClientHello = errors.New("client hello: truncated input")
+
+	// ErrorNotClientHello indicates that the input does not contain a client
+	// hello handshake message.
+	ErrorNotClientHello = errors.New("client hello: not a client hello")
+)
+
+// ParseClientHello parses a TLS client hello and returns the corresponding
+// request fingerprint. The input may either be one or more TLS records
+// carrying the handshake message (as read from the wire), the bare handshake
+// message starting with the handshake type, or an SSL 2.0 compatible client
+// hello record. The header field of the returned fingerprint is always empty,
+// as it is not part of the client hello.
+func ParseClientHello(data []byte) (RequestFingerprint, error) {
+	var a RequestFingerprint
+	if len(data) == 0 {
+		return a, ErrorShortClientHello
+	}
+	msg := data
+	if data[0] == recordTypeHandshake {
+		var err error
+		if msg, err = clientHelloFromRecords(data); err != nil {
+			return a, err
+		}
+	} else if data[0]&0x80 != 0 {
+		err := a.parseSSLv2ClientHello(data)
+		return a, err
+	}
+	err := a.parseClientHelloMessage(msg)
+	return a, err
+}
+
+// clientHelloFromRecords reassembles the client hello handshake message from
+// a sequence of TLS handshake records.
+func clientHelloFromRecords(data []byte) ([]byte, error) {
+	var msg []byte
+	for len(data) > 0 {
+		if len(data) < recordHeaderLen {
+			return nil, ErrorShortClientHello
+		}
+		if data[0] != recordTypeHandshake {
+			return nil, fmt.Errorf("client hello: unexpected record type 0x%02x", data[0])
+		}
+		if data[1] != 0x03 {
+			return nil, fmt.Errorf("client hello: unexpected record version 0x%02x%02x", data[1], data[2])
+		}
+		length := int(data[3])<<8 | int(data[4])
+		if length > maxRecordPayloadLen {
+			return nil, fmt.Errorf("client hello: record length %d too large", length)
+		}
+		data = data[recordHeaderLen:]
+		if len(data) < length {
+			// keep the partial fragment; the check below reports truncation
+			length = len(data)
+		}
+		msg = append(msg, data[:length]...)
+		data = data[length:]
+		// stop as soon as the handshake message is complete, ignoring any
+		// records that follow it
+		if len(msg) >= handshakeHeaderLen {
+			msgLen := int(msg[1])<<16 | int(msg[2])<<8 | int(msg[3])
+			if len(msg) >= handshakeHeaderLen+msgLen {
+				return msg[:handshakeHeaderLen+msgLen], nil
+			}
+		}
+	}
+	return nil, ErrorShortClientHello
+}
+
+// parseSSLv2ClientHello fills in the fingerprint from an SSL 2.0 compatible
+// client hello record, which has a two-byte header with the high bit set. The
+// record carries no extensions, so only the version and cipher fields are set,
+// along with the "v2" quirk. Cipher specs are three bytes long: those whose
+// first byte is zero are TLS cipher suites, and the others are SSL 2.0 cipher
+// kinds, which are kept as 24-bit values.
+func (a *RequestFingerprint) parseSSLv2ClientHello(data []byte) error {
+	if len(data) < sslv2RecordHeaderLen {
+		return ErrorShortClientHello
+	}
+	length := (int(data[0])<<8 | int(data[1])) & sslv2RecordLengthMask
+	data = data[sslv2RecordHeaderLen:]
+	if len(data) < length || length < sslv2HelloHeaderLen {
+		return ErrorShortClientHello
+	}
+	r := helloReader(data[:length])
+	if msgType, _ := r.readUint8(); msgType != handshakeTypeClientHello {
+		return ErrorNotClientHello
+	}
+	version, _ := r.readUint16()
+	cipherSpecLen, _ := r.readUint16()
+	sessionIDLen, _ := r.readUint16()
+	challengeLen, _ := r.readUint16()
+	if cipherSpecLen%uint16(sslv2CipherSpecLen) != 0 {
+		return fmt.Errorf("client hello: malformed sslv2 cipher specs")
+	}
+	if len(r) < int(cipherSpecLen)+int(sessionIDLen)+int(challengeLen) {
+		return ErrorShortClientHello
+	}
+	if err := a.Version.Parse(strconv.FormatUint(uint64(version), 16)); err != nil {
+		return fmt.Errorf("client hello: %s", err)
+	}
+	for specs := r[:cipherSpecLen]; len(specs) > 0; specs = specs[sslv2CipherSpecLen:] {
+		a.Cipher = append(a.Cipher, int(specs[0])<<16|int(specs[1])<<8|int(specs[2]))
+	}
+	a.Quirk = append(a.Quirk, "v2")
+	return nil
+}
+
+// parseClientHelloMessage fills in the fingerprint from a client hello
+// handshake message.
+func (a *RequestFingerprint) parseClientHelloMessage(msg []byte) error {
+	r := helloReader(msg)
+	msgType, ok := r.readUint8()
+	if !ok {
+		return ErrorShortClientHello
+	}
+	if msgType != handshakeTypeClientHello {
+		return ErrorNotClientHello
+	}
+	body, ok := r.readVector(3)
+	if !ok {
+		return ErrorShortClientHello
+	}
+	r = helloReader(body)
+
+	// legacy_version
+	version, ok := r.readUint16()
+	if !ok {
+		return ErrorShortClientHello
+	}
+	if err := a.Version.Parse(strconv.FormatUint(uint64(version), 16)); err != nil {
+		return fmt.Errorf("client hello: %s", err)
+	}
+	// random (32 bytes) and legacy_session_id
+	if !r.skip(32) {
+		return ErrorShortClientHello
+	}
+	if _, ok = r.readVector(1); !ok {
+		return ErrorShortClientHello
+	}
+	// cipher_suites
+	ciphers, ok := r.readVector(2)
+	if !ok || len(ciphers)%2 != 0 {
+		return ErrorShortClientHello
+	}
+	a.Cipher = helloReader(ciphers).readUint16List()
+	// legacy_compression_methods
+	compression, ok := r.readVector(1)
+	if !ok {
+		return ErrorShortClientHello
+	}
+	if len(compression) > 1 {
+		a.Quirk = append(a.Quirk, "compr")
+	}
+	// extensions are optional in older client hellos
+	if len(r) == 0 {
+		return nil
+	}
+	extensions, ok := r.readVector(2)
+	if !ok {
+		return ErrorShortClientHello
+	}
+	r = helloReader(extensions)
+	for len(r) > 0 {
+		extType, ok := r.readUint16()
+		if !ok {
+			return ErrorShortClientHello
+		}
+		extData, ok := r.readVector(2)
+		if !ok {
+			return ErrorShortClientHello
+		}
+		a.Extension = append(a.Extension, int(extType))
+		if err := a.parseClientHelloExtension(extType, extData); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseClientHelloExtension fills in the fingerprint fields derived from the
+// contents of a single client hello extension.
+func (a *RequestFingerprint) parseClientHelloExtension(extType uint16, extData []byte) error {
+	r := helloReader(extData)
+	switch extType {
+	case extensionServerName:
+		a.SNI = true
+	case extensionSupportedGroups:
+		groups, ok := r.readVector(2)
+		if !ok || len(groups)%2 != 0 {
+			return fmt.Errorf("client hello: malformed supported groups extension")
+		}
+		a.Curve = helloReader(groups).readUint16List()
+	case extensionEcPointFormats:
+		formats, ok := r.readVector(1)
+		if !ok {
+			return fmt.Errorf("client hello: malformed ec point formats extension")
+		}
+		for _, elem := range formats {
+			a.EcPointFmt = append(a.EcPointFmt, int(elem))
+		}
+	case extensionSignatureAlgorithms:
+		algorithms, ok := r.readVector(2)
+		if !ok || len(algorithms)%2 != 0 {
+			return fmt.Errorf("client hello: malformed signature algorithms extension")
+		}
+		a.SigAlg = helloReader(algorithms).readUint16List()
+	case extensionALPN:
+		protocols, ok := r.readVector(2)
+		if !ok {
+			return fmt.Errorf("client hello: malformed alpn extension")
+		}
+		r = helloReader(protocols)
+		for len(r) > 0 {
+			protocol, ok := r.readVector(1)
+			if !ok || len(protocol) == 0 {
+				return fmt.Errorf("client hello: malformed alpn extension")
+			}
+			a.ALPN = append(a.ALPN, string(protocol))
+		}
+	case extensionSupportedVersions:
+		versions, ok := r.readVector(1)
+		if !ok || len(versions)%2 != 0 {
+			return fmt.Errorf("client hello: malformed supported versions extension")
+		}
+		a.SupportedVersion = helloReader(versions).readUint16List()
+	case extensionKeyShare:
+		shares, ok := r.readVector(2)
+		if !ok {
+			return fmt.Errorf("client hello: malformed key share extension")
+		}
+		r = helloReader(shares)
+		for len(r) > 0 {
+			group, ok := r.readUint16()
+			if !ok {
+				return fmt.Errorf("client hello: malformed key share extension")
+			}
+			if _, ok = r.readVector(2); !ok {
+				return fmt.Errorf("client hello: malformed key share extension")
+			}
+			a.KeyShare = append(a.KeyShare, int(group))
+		}
+	}
+	return nil
+}
+
+// A helloReader consumes big-endian integers and length-prefixed vectors from
+// a byte slice.
+type helloReader []byte
+
+// skip advances the reader by n bytes.
+func (r *helloReader) skip(n int) bool {
+	if len(*r) < n {
+		return false
+	}
+	*r = (*r)[n:]
+	return true
+}
+
+// readUint8 reads a single byte.
+func (r *helloReader) readUint8() (uint8, bool) {
+	if len(*r) < 1 {
+		return 0, false
+	}
+	v := (*r)[0]
+	*r = (*r)[1:]
+	return v, true
+}
+
+// readUint16 reads a big-endian 16-bit integer.
+func (r *helloReader) readUint16() (uint16, bool) {
+	if len(*r) < 2 {
+		return 0, false
+	}
+	v := uint16((*r)[0])<<8 | uint16((*r)[1])
+	*r = (*r)[2:]
+	return v, true
+}
+
+// readVector reads a vector prefixed with a lenBytes-byte big-endian length.
+func (r *helloReader) readVector(lenBytes int) ([]byte, bool) {
+	if len(*r) < lenBytes {
+		return nil, false
+	}
+	length := 0
+	for _, b := range (*r)[:lenBytes] {
+		length = length<<8 | int(b)
+	}
+	*r = (*r)[lenBytes:]
+	if len(*r) < length {
+		return nil, false
+	}
+	v := (*r)[:length]
+	*r = (*r)[length:]
+	return v, true
+}
+
+// readUint16List reads the remainder of the reader as a list of big-endian
+// 16-bit integers.
+func (r helloReader) readUint16List() IntList {
+	var list IntList
+	for len(r) >= 2 {
+		v, _ := r.readUint16()
+		list = append(list, int(v))
+	}
+	return list
+}
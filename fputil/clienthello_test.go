@@ -0,0 +1,100 @@
+package fp_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	fp "github.com/cloudflare/mitmengine/fputil"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+// A TLS 1.2 client hello record sent by crypto/tls with server name, ALPN, and
+// a restricted set of cipher suites and curves.
+const goClientHelloRecord = "16030100de010000da0303175b45dac58360b288a7fae19c89a9c675c8325258cd4eaccf752e317bf835bc2090d63ded0778b48ba89eb86f16113a03611d046f6bdf1db15b65476d60edd5f50006c02fc030009c0100008b00000010000e00000b6578616d706c652e636f6d000b00020100ff010001000017000000120000000500050100000000000a0006000400170018000d0016001408040403080708050806040105010601050306030032001a00180804040308070805080604010501060105030603020102030010000e000c02683208687474702f312e31002b0003020303"
+
+// A TLS 1.3 client hello record sent by crypto/tls with supported versions
+// and a single X25519 key share.
+const goTLS13ClientHelloRecord = "160301010f0100010b0303f1d434523ab5e61f270cfa67bdd2a561275cb8e5dd4250a5849a9f8bb1812e8420dbb9a3970dfc63b66243143f48277dacde488d063da066e2d3a52961575651a70008c02f130113021303010000ba00000010000e00000b6578616d706c652e636f6d000b00020100ff010001000017000000120000000500050100000000000a00060004001d0017000d001c001a090409050906080404030807080508060401050106010503060300320020001e090409050906080404030807080508060401050106010503060302010203001000050003026832002b00050403040303003300260024001d0020aacffc4192dd45df8ca67efb26b82f1dddfaae183c2560729978b74fff4c8673"
+
+// A bare SSL 3.0 client hello handshake message with two compression methods
+// and no extensions.
+const ssl3ClientHelloMessage = "0100002c0300" +
+	"0000000000000000000000000000000000000000000000000000000000000000" +
+	"00" + "0004000a0005" + "020100"
+
+// An SSL 2.0 compatible client hello record sent by Microsoft Threat
+// Management Gateway, offering SSL 3.0 with both TLS cipher suites and SSL 2.0
+// cipher kinds.
+const sslv2ClientHelloRecord = "802e01030000150000001000000a0000130000050000040100800700c00000ff" +
+	"ea0a997f4e67d1ee725e4e80002a6098"
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	testutil.Ok(t, err)
+	return b
+}
+
+func TestParseClientHello(t *testing.T) {
+	var tests = []struct {
+		in   string
+		out  string
+		alpn fp.StringList
+		sni  bool
+	}{
+		{goClientHelloRecord, "303:c02f,c030,9c:0,b,ff01,17,12,5,a,d,32,10,2b:17,18:0:::804,403,807,805,806,401,501,601,503,603:h2,http/1.1:303", fp.StringList{"h2", "http/1.1"}, true},
+		{goTLS13ClientHelloRecord, "303:c02f,1301,1302,1303:0,b,ff01,17,12,5,a,d,32,10,2b,33:1d,17:0:::904,905,906,804,403,807,805,806,401,501,601,503,603:h2:304,303:1d", fp.StringList{"h2"}, true},
+		{ssl3ClientHelloMessage, "300:a,5:::::compr", nil, false},
+		{sslv2ClientHelloRecord, "300:a,13,5,4,10080,700c0,ff:::::v2", nil, false},
+	}
+	for _, test := range tests {
+		actual, err := fp.ParseClientHello(mustDecodeHex(t, test.in))
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.out, actual.String())
+		testutil.Equals(t, test.alpn, actual.ALPN)
+		testutil.Equals(t, test.sni, actual.SNI)
+		expected, err := fp.NewRequestFingerprint(test.out)
+		testutil.Ok(t, err)
+		testutil.Equals(t, expected, actual)
+	}
+}
+
+func TestParseClientHelloFragmented(t *testing.T) {
+	record := mustDecodeHex(t, goClientHelloRecord)
+	msg := record[5:]
+	// split the handshake message over two records
+	split := []byte{0x16, 0x03, 0x01, 0x00, 0x10}
+	split = append(split, msg[:0x10]...)
+	split = append(split, 0x16, 0x03, 0x01, byte((len(msg)-0x10)>>8), byte(len(msg)-0x10))
+	split = append(split, msg[0x10:]...)
+	expected, err := fp.ParseClientHello(record)
+	testutil.Ok(t, err)
+	actual, err := fp.ParseClientHello(split)
+	testutil.Ok(t, err)
+	testutil.Equals(t, expected, actual)
+	// the bare handshake message gives the same result
+	actual, err = fp.ParseClientHello(msg)
+	testutil.Ok(t, err)
+	testutil.Equals(t, expected, actual)
+}
+
+func TestParseClientHelloErrors(t *testing.T) {
+	record := mustDecodeHex(t, goClientHelloRecord)
+	sslv2Record := mustDecodeHex(t, sslv2ClientHelloRecord)
+	var tests = []struct {
+		in  []byte
+		out error
+	}{
+		{nil, fp.ErrorShortClientHello},
+		{record[:3], fp.ErrorShortClientHello},
+		{record[:len(record)-1], fp.ErrorShortClientHello},
+		{record[5:50], fp.ErrorShortClientHello},
+		{[]byte{0x02, 0x00, 0x00, 0x00}, fp.ErrorNotClientHello},
+		{sslv2Record[:1], fp.ErrorShortClientHello},
+		{sslv2Record[:len(sslv2Record)-1], fp.ErrorShortClientHello},
+		{[]byte{0x80, 0x09, 0x04, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, fp.ErrorNotClientHello},
+	}
+	for _, test := range tests {
+		_, err := fp.ParseClientHello(test.in)
+		testutil.Equals(t, test.out, err)
+	}
+}
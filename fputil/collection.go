@@ -38,7 +38,8 @@ func (a *IntList) Parse(s string) error {
 		if len(v) == 0 {
 			return fmt.Errorf("invalid int list format: '%s'", s)
 		}
-		elem64bit, err := strconv.ParseUint(v, 16, 16)
+		// values are up to 24 bits long to hold SSL 2.0 cipher kinds
+		elem64bit, err := strconv.ParseUint(v, 16, 24)
 		elem := int(elem64bit)
 		if err != nil {
 			return err
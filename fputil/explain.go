@@ -0,0 +1,168 @@
+package fp
+
+// An IntExplanation lists the elements of an int list that keep it from
+// matching an int signature.
+type IntExplanation struct {
+	// Missing lists the required elements that are not in the list.
+	Missing IntList
+
+	// Excluded lists the excluded elements that are in the list.
+	Excluded IntList
+
+	// Extra lists the elements in the list that the signature does not
+	// allow.
+	Extra IntList
+
+	// Unlikely lists the unlikely elements that are in the list.
+	Unlikely IntList
+
+	// OutOfOrder is the first pair of elements in the list that appear in the
+	// opposite order in the signature's ordered list, or nil if the order
+	// matches.
+	OutOfOrder IntList
+}
+
+// A StringExplanation lists the elements of a string list that keep it from
+// matching a string signature.
+type StringExplanation struct {
+	// Missing lists the required elements that are not in the list.
+	Missing StringList
+
+	// Excluded lists the excluded elements that are in the list.
+	Excluded StringList
+
+	// Extra lists the elements in the list that the signature does not
+	// allow.
+	Extra StringList
+
+	// Unlikely lists the unlikely elements that are in the list.
+	Unlikely StringList
+
+	// OutOfOrder is the first pair of elements in the list that appear in the
+	// opposite order in the signature's ordered list, or nil if the order
+	// matches.
+	OutOfOrder StringList
+}
+
+// IsEmpty returns true if the explanation does not list any elements.
+func (a IntExplanation) IsEmpty() bool {
+	return len(a.Missing) == 0 && len(a.Excluded) == 0 && len(a.Extra) == 0 && len(a.Unlikely) == 0 && len(a.OutOfOrder) == 0
+}
+
+// IsEmpty returns true if the explanation does not list any elements.
+func (a StringExplanation) IsEmpty() bool {
+	return len(a.Missing) == 0 && len(a.Excluded) == 0 && len(a.Extra) == 0 && len(a.Unlikely) == 0 && len(a.OutOfOrder) == 0
+}
+
+// Explain returns the elements of the list that keep it from matching the
+// signature, following the same rules as Match. Missing elements are listed
+// in the order of the signature, and other elements in the order of the list.
+// Extra elements are only listed if the signature has an ordered list or
+// optional elements, since any other elements are allowed otherwise.
+func (a IntSignature) Explain(list IntList) IntExplanation {
+	var explanation IntExplanation
+	set := list.Set()
+	for _, elem := range a.requiredList() {
+		if !set.Has(elem) {
+			explanation.Missing = append(explanation.Missing, elem)
+		}
+	}
+	checkExtra := a.OrderedList != nil || !a.OptionalSet.IsEmpty()
+	for _, elem := range list {
+		switch {
+		case a.ExcludedSet.Has(elem):
+			explanation.Excluded = append(explanation.Excluded, elem)
+		case a.UnlikelySet.Has(elem):
+			explanation.Unlikely = append(explanation.Unlikely, elem)
+		case checkExtra && !a.RequiredSet.Has(elem) && !a.OptionalSet.Has(elem):
+			explanation.Extra = append(explanation.Extra, elem)
+		}
+	}
+	position := make(map[int]int, len(a.OrderedList))
+	for idx, elem := range a.OrderedList {
+		position[elem] = idx
+	}
+	maxIdx := -1
+	for idx, elem := range list {
+		elemPosition, ok := position[elem]
+		if !ok {
+			continue
+		}
+		if maxIdx >= 0 && elemPosition <= position[list[maxIdx]] {
+			explanation.OutOfOrder = IntList{list[maxIdx], elem}
+			break
+		}
+		maxIdx = idx
+	}
+	return explanation
+}
+
+// Explain returns the elements of the list that keep it from matching the
+// signature, as described for IntSignature.Explain.
+func (a StringSignature) Explain(list StringList) StringExplanation {
+	var explanation StringExplanation
+	set := list.Set()
+	for _, elem := range a.requiredList() {
+		if !set[elem] {
+			explanation.Missing = append(explanation.Missing, elem)
+		}
+	}
+	checkExtra := a.OrderedList != nil || len(a.OptionalSet) != 0
+	for _, elem := range list {
+		switch {
+		case a.ExcludedSet[elem]:
+			explanation.Excluded = append(explanation.Excluded, elem)
+		case a.UnlikelySet[elem]:
+			explanation.Unlikely = append(explanation.Unlikely, elem)
+		case checkExtra && !a.RequiredSet[elem] && !a.OptionalSet[elem]:
+			explanation.Extra = append(explanation.Extra, elem)
+		}
+	}
+	position := make(map[string]int, len(a.OrderedList))
+	for idx, elem := range a.OrderedList {
+		position[elem] = idx
+	}
+	maxIdx := -1
+	for idx, elem := range list {
+		elemPosition, ok := position[elem]
+		if !ok {
+			continue
+		}
+		if maxIdx >= 0 && elemPosition <= position[list[maxIdx]] {
+			explanation.OutOfOrder = StringList{list[maxIdx], elem}
+			break
+		}
+		maxIdx = idx
+	}
+	return explanation
+}
+
+// requiredList returns the required elements of the signature, in the order
+// of the ordered list if there is one and in sorted order otherwise.
+func (a IntSignature) requiredList() IntList {
+	if a.OrderedList == nil {
+		return a.RequiredSet.List()
+	}
+	var list IntList
+	for _, elem := range a.OrderedList {
+		if a.RequiredSet.Has(elem) {
+			list = append(list, elem)
+		}
+	}
+	return list
+}
+
+// requiredList returns the required elements of the signature, in the order
+// of the ordered list if there is one and in sorted order otherwise.
+func (a StringSignature) requiredList() StringList {
+	if a.OrderedList == nil {
+		return a.RequiredSet.List()
+	}
+	var list StringList
+	for _, elem := range a.OrderedList {
+		if a.RequiredSet[elem] {
+			list = append(list, elem)
+		}
+	}
+	return list
+}
@@ -0,0 +1,65 @@
+package fp_test
+
+import (
+	"testing"
+
+	fp "github.com/cloudflare/mitmengine/fputil"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+func TestIntSignatureExplain(t *testing.T) {
+	var tests = []struct {
+		in1 string
+		in2 string
+		out fp.IntExplanation
+	}{
+		{"", "", fp.IntExplanation{}},
+		{"*", "1,2", fp.IntExplanation{}},
+		{"*1,^2", "1,2", fp.IntExplanation{Excluded: fp.IntList{2}}},
+		{"~1,^2", "2", fp.IntExplanation{Missing: fp.IntList{1}, Excluded: fp.IntList{2}}},
+		{"1,2", "2,1", fp.IntExplanation{OutOfOrder: fp.IntList{2, 1}}},
+		{"1,2,3", "1,3,2", fp.IntExplanation{OutOfOrder: fp.IntList{3, 2}}},
+		{"1,2,3", "3,2,1", fp.IntExplanation{OutOfOrder: fp.IntList{3, 2}}},
+		{"1,2", "1,1", fp.IntExplanation{Missing: fp.IntList{2}, OutOfOrder: fp.IntList{1, 1}}},
+		{"1,2", "1,2,3", fp.IntExplanation{Extra: fp.IntList{3}}},
+		{"3,1,?2", "4,3", fp.IntExplanation{Missing: fp.IntList{1}, Extra: fp.IntList{4}}},
+		{"*1,2", "3,2,1", fp.IntExplanation{}},
+		{"1,!2,?3", "1,2,3", fp.IntExplanation{Unlikely: fp.IntList{2}}},
+		{"~1,?2", "1,4", fp.IntExplanation{Extra: fp.IntList{4}}},
+	}
+	for _, test := range tests {
+		signature, err := fp.NewIntSignature(test.in1)
+		testutil.Ok(t, err)
+		list, err := fp.NewIntList(test.in2)
+		testutil.Ok(t, err)
+		explanation := signature.Explain(list)
+		testutil.Equals(t, test.out, explanation)
+		match, _ := signature.Match(list)
+		testutil.Assert(t, explanation.IsEmpty() == (match == fp.MatchPossible), "explanation of '%s' vs '%s' does not agree with match %s", test.in1, test.in2, match)
+	}
+}
+
+func TestStringSignatureExplain(t *testing.T) {
+	var tests = []struct {
+		in1 string
+		in2 string
+		out fp.StringExplanation
+	}{
+		{"", "", fp.StringExplanation{}},
+		{"*", "a,b", fp.StringExplanation{}},
+		{"h2,http/1.1", "h2", fp.StringExplanation{Missing: fp.StringList{"http/1.1"}}},
+		{"h2,http/1.1", "http/1.1,h2", fp.StringExplanation{OutOfOrder: fp.StringList{"http/1.1", "h2"}}},
+		{"*^badhdr", "grease,badhdr", fp.StringExplanation{Excluded: fp.StringList{"badhdr"}}},
+		{"a,?b,!c", "a,c,d", fp.StringExplanation{Unlikely: fp.StringList{"c"}, Extra: fp.StringList{"d"}}},
+	}
+	for _, test := range tests {
+		signature, err := fp.NewStringSignature(test.in1)
+		testutil.Ok(t, err)
+		list, err := fp.NewStringList(test.in2)
+		testutil.Ok(t, err)
+		explanation := signature.Explain(list)
+		testutil.Equals(t, test.out, explanation)
+		match := signature.Match(list)
+		testutil.Assert(t, explanation.IsEmpty() == (match == fp.MatchPossible), "explanation of '%s' vs '%s' does not agree with match %s", test.in1, test.in2, match)
+	}
+}
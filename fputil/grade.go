@@ -23,6 +23,33 @@ func (a Grade) String() string {
 	}
 }
 
+// NewGrade parses a grade from a string as returned by String, or from an
+// empty string for GradeEmpty
+func NewGrade(s string) (Grade, error) {
+	var a Grade
+	err := a.Parse(s)
+	return a, err
+}
+
+// Parse initializes a grade from a string
+func (a *Grade) Parse(s string) error {
+	switch s {
+	case "", "empty":
+		*a = GradeEmpty
+	case "A":
+		*a = GradeA
+	case "B":
+		*a = GradeB
+	case "C":
+		*a = GradeC
+	case "F":
+		*a = GradeF
+	default:
+		return fmt.Errorf("invalid grade: '%s'", s)
+	}
+	return nil
+}
+
 // Merge returns the weakest of two security grades
 func (a Grade) Merge(b Grade) Grade {
 	if a > b {
@@ -31,6 +58,54 @@ func (a Grade) Merge(b Grade) Grade {
 	return b
 }
 
+// A GradeFactor is a value that contributes to a security grade. Name
+// describes the kind of factor, such as "version" or "mitm", Value is the value
+// that the factor is about, with integers in hex, and Grade is the grade that
+// it contributes.
+type GradeFactor struct {
+	Name  string
+	Value string
+	Grade Grade
+}
+
+// A GradeExplanation is a security grade along with the factors that
+// contribute to it. Grade is the weakest grade of the factors, or GradeEmpty if
+// there are none.
+type GradeExplanation struct {
+	Grade   Grade
+	Factors []GradeFactor
+}
+
+// Add adds a factor to the explanation and merges its grade. Factors with
+// GradeEmpty do not contribute to a grade, so they are ignored.
+func (a *GradeExplanation) Add(factor GradeFactor) {
+	if factor.Grade == GradeEmpty {
+		return
+	}
+	a.Factors = append(a.Factors, factor)
+	a.Grade = a.Grade.Merge(factor.Grade)
+}
+
+// Merge returns an explanation with the factors of both explanations and the
+// weakest of their grades.
+func (a GradeExplanation) Merge(b GradeExplanation) GradeExplanation {
+	var merged GradeExplanation
+	for _, factor := range a.Factors {
+		merged.Add(factor)
+	}
+	for _, factor := range b.Factors {
+		merged.Add(factor)
+	}
+	return merged
+}
+
+// Names of grade factors, in addition to the names of security findings.
+const (
+	GradeFactorVersion     string = "version"
+	GradeFactorFirstCipher string = "first_cipher"
+	GradeFactorMitm        string = "mitm"
+)
+
 // Sources:
 //  - https://jhalderm.com/pub/papers/interception-ndss17.pdf
 const (
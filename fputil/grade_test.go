@@ -26,6 +26,28 @@ func TestGradeString(t *testing.T) {
 	}
 }
 
+func TestNewGrade(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out fp.Grade
+	}{
+		{"", fp.GradeEmpty},
+		{"empty", fp.GradeEmpty},
+		{"A", fp.GradeA},
+		{"B", fp.GradeB},
+		{"C", fp.GradeC},
+		{"F", fp.GradeF},
+	}
+
+	for _, test := range tests {
+		actual, err := fp.NewGrade(test.in)
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.out, actual)
+	}
+	_, err := fp.NewGrade("D")
+	testutil.Assert(t, err != nil, "expected error for grade D")
+}
+
 func TestGradeMerge(t *testing.T) {
 	var tests = []struct {
 		in1 fp.Grade
@@ -45,3 +67,34 @@ func TestGradeMerge(t *testing.T) {
 		testutil.Equals(t, test.out, actual)
 	}
 }
+
+func TestGradeExplanationMerge(t *testing.T) {
+	version := fp.GradeFactor{Name: fp.GradeFactorVersion, Value: "301", Grade: fp.GradeB}
+	cipher := fp.GradeFactor{Name: fp.GradeFactorFirstCipher, Value: "c02b", Grade: fp.GradeA}
+	mitm := fp.GradeFactor{Name: fp.GradeFactorMitm, Value: "avast", Grade: fp.GradeF}
+	var tests = []struct {
+		in1 fp.GradeExplanation
+		in2 fp.GradeExplanation
+		out fp.GradeExplanation
+	}{
+		{fp.GradeExplanation{}, fp.GradeExplanation{}, fp.GradeExplanation{}},
+		{fp.GradeExplanation{Grade: fp.GradeB, Factors: []fp.GradeFactor{version}}, fp.GradeExplanation{}, fp.GradeExplanation{Grade: fp.GradeB, Factors: []fp.GradeFactor{version}}},
+		{fp.GradeExplanation{Grade: fp.GradeB, Factors: []fp.GradeFactor{version}}, fp.GradeExplanation{Grade: fp.GradeA, Factors: []fp.GradeFactor{cipher}}, fp.GradeExplanation{Grade: fp.GradeB, Factors: []fp.GradeFactor{version, cipher}}},
+		{fp.GradeExplanation{Grade: fp.GradeA, Factors: []fp.GradeFactor{cipher}}, fp.GradeExplanation{Grade: fp.GradeF, Factors: []fp.GradeFactor{mitm}}, fp.GradeExplanation{Grade: fp.GradeF, Factors: []fp.GradeFactor{cipher, mitm}}},
+	}
+
+	for _, test := range tests {
+		actual := test.in1.Merge(test.in2)
+		testutil.Equals(t, test.out, actual)
+	}
+}
+
+func TestGradeExplanationAdd(t *testing.T) {
+	var explanation fp.GradeExplanation
+	explanation.Add(fp.GradeFactor{Name: fp.GradeFactorFirstCipher, Value: "1301", Grade: fp.GradeEmpty})
+	testutil.Equals(t, fp.GradeExplanation{}, explanation)
+	explanation.Add(fp.GradeFactor{Name: fp.GradeFactorVersion, Value: "300", Grade: fp.GradeC})
+	explanation.Add(fp.GradeFactor{Name: fp.GradeFactorFirstCipher, Value: "c02b", Grade: fp.GradeA})
+	testutil.Equals(t, fp.GradeC, explanation.Grade)
+	testutil.Equals(t, 2, len(explanation.Factors))
+}
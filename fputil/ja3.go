@@ -0,0 +1,224 @@
+package fp
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JA3 strings have the format
+// 	<version>,<cipher>,<extension>,<curve>,<ecpointfmt>
+// where <version> is the decimal-encoded TLS version from the client hello,
+// and the remaining parts are dash-separated lists of decimal-encoded values.
+// GREASE values are not included. The JA3 hash is the hex-encoded MD5 digest
+// of the JA3 string.
+// Source:
+//  - https://github.com/salesforce/ja3
+
+const (
+	ja3FieldCount int    = 5
+	ja3FieldSep   string = ","
+	ja3ElemSep    string = "-"
+
+	// maxJA3Count is the maximum number of JA3 strings that are enumerated
+	// for a single request signature.
+	maxJA3Count int = 4096
+)
+
+var (
+	// ErrorSignatureNotOrdered indicates that a request signature does not
+	// have a bounded version and an ordered list for each of the client
+	// hello fields used by JA3, so its JA3 strings cannot be enumerated.
+	ErrorSignatureNotOrdered = errors.New("ja3: signature is not fully ordered")
+
+	// ErrorTooManyJA3 indicates that a request signature matches too many
+	// JA3 strings to enumerate.
+	ErrorTooManyJA3 = errors.New("ja3: signature matches too many fingerprints")
+
+	// ErrorNoJA3Version indicates that a request signature does not match
+	// any version, so it matches no JA3 strings.
+	ErrorNoJA3Version = errors.New("ja3: signature matches no version")
+)
+
+// knownVersions contains the versions that can appear in a JA3 string, in
+// increasing order.
+var knownVersions = []Version{VersionSSL2, VersionSSL3, VersionTLS10, VersionTLS11, VersionTLS12, VersionTLS13}
+
+// NewRequestFingerprintFromJA3 returns a new request fingerprint parsed from a
+// JA3 string. The header and quirk fields of the fingerprint are left empty,
+// and any GREASE values are kept.
+func NewRequestFingerprintFromJA3(s string) (RequestFingerprint, error) {
+	var a RequestFingerprint
+	fields := strings.Split(strings.TrimSpace(s), ja3FieldSep)
+	if len(fields) != ja3FieldCount {
+		return a, fmt.Errorf("bad ja3 field count '%s': exp %d, got %d", s, ja3FieldCount, len(fields))
+	}
+	version, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return a, err
+	}
+	if version != 0 {
+		if err := a.Version.Parse(strconv.FormatUint(version, 16)); err != nil {
+			return a, err
+		}
+	}
+	for idx, list := range []*IntList{&a.Cipher, &a.Extension, &a.Curve, &a.EcPointFmt} {
+		if *list, err = parseJA3List(fields[idx+1]); err != nil {
+			return a, err
+		}
+	}
+	a.SNI = a.Extension.Set().Has(int(extensionServerName))
+	return a, nil
+}
+
+// parseJA3List parses a dash-separated list of decimal-encoded values.
+func parseJA3List(s string) (IntList, error) {
+	var list IntList
+	if len(s) == 0 {
+		return list, nil
+	}
+	for _, v := range strings.Split(s, ja3ElemSep) {
+		elem, err := strconv.ParseUint(v, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ja3 list format: '%s'", s)
+		}
+		list = append(list, int(elem))
+	}
+	return list, nil
+}
+
+// JA3 returns the JA3 string for the fingerprint.
+func (a RequestFingerprint) JA3() string {
+	return ja3String(a.Version, a.Cipher, a.Extension, a.Curve, a.EcPointFmt)
+}
+
+// JA3Hash returns the JA3 hash for the fingerprint.
+func (a RequestFingerprint) JA3Hash() string {
+	return ja3Hash(a.JA3())
+}
+
+// JA3 returns the sorted JA3 strings of all fingerprints that can match the
+// signature, ignoring the header and quirk fields. The signature must be
+// fully ordered: the version must have both a minimum and a maximum, and the
+// cipher, extension, curve, and ecpointfmt fields must each have an ordered
+// list. Elements that are not required can be present or absent. The matching
+// versions are the known versions and the version bounds, so that signatures
+// for draft, experimental, or GREASE versions are enumerated too.
+func (a RequestSignature) JA3() ([]string, error) {
+	var versions []Version
+	if a.Version.Min == VersionEmpty || a.Version.Max == VersionEmpty {
+		return nil, ErrorSignatureNotOrdered
+	}
+	seen := make(map[Version]bool)
+	for _, version := range append(knownVersions[:len(knownVersions):len(knownVersions)], a.Version.Min, a.Version.Max) {
+		if !seen[version] && a.Version.Match(version) != MatchImpossible {
+			versions = append(versions, version)
+		}
+		seen[version] = true
+	}
+	count := len(versions)
+	if count == 0 {
+		return nil, ErrorNoJA3Version
+	}
+	var fields [][]IntList
+	for _, signature := range []IntSignature{a.Cipher, a.Extension, a.Curve, a.EcPointFmt} {
+		lists, err := signature.orderedLists(maxJA3Count / count)
+		if err != nil {
+			return nil, err
+		}
+		if count *= len(lists); count > maxJA3Count {
+			return nil, ErrorTooManyJA3
+		}
+		fields = append(fields, lists)
+	}
+	var ja3 []string
+	for _, version := range versions {
+		for _, cipher := range fields[0] {
+			for _, extension := range fields[1] {
+				for _, curve := range fields[2] {
+					for _, ecPointFmt := range fields[3] {
+						ja3 = append(ja3, ja3String(version, cipher, extension, curve, ecPointFmt))
+					}
+				}
+			}
+		}
+	}
+	sort.Strings(ja3)
+	return ja3, nil
+}
+
+// JA3Hashes returns the sorted JA3 hashes of all fingerprints that can match
+// the signature, as described for JA3.
+func (a RequestSignature) JA3Hashes() ([]string, error) {
+	ja3, err := a.JA3()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, len(ja3))
+	for idx, elem := range ja3 {
+		hashes[idx] = ja3Hash(elem)
+	}
+	sort.Strings(hashes)
+	return hashes, nil
+}
+
+// orderedLists returns each list that matches an ordered int signature, or an
+// error if the signature is not ordered or matches more than limit lists.
+func (a IntSignature) orderedLists(limit int) ([]IntList, error) {
+	if a.OrderedList == nil {
+		return nil, ErrorSignatureNotOrdered
+	}
+	lists := []IntList{nil}
+	for _, elem := range a.OrderedList {
+		required := a.RequiredSet.Has(elem)
+		var next []IntList
+		for _, list := range lists {
+			if !required {
+				next = append(next, list)
+			}
+			next = append(next, append(list[:len(list):len(list)], elem))
+		}
+		if len(next) > limit {
+			return nil, ErrorTooManyJA3
+		}
+		lists = next
+	}
+	return lists, nil
+}
+
+// ja3String returns the JA3 string for the client hello fields, skipping any
+// GREASE values.
+func ja3String(version Version, cipher, extension, curve, ecPointFmt IntList) string {
+	if version == VersionSSL2 {
+		version = 0x0002 // swap back to the wire encoding
+	}
+	return strings.Join([]string{
+		strconv.Itoa(int(version)),
+		ja3List(cipher),
+		ja3List(extension),
+		ja3List(curve),
+		ja3List(ecPointFmt),
+	}, ja3FieldSep)
+}
+
+// ja3List returns a dash-separated list of the decimal-encoded non-GREASE
+// values in the list.
+func ja3List(list IntList) string {
+	var fields []string
+	for _, elem := range list {
+		if !IsGrease(elem) {
+			fields = append(fields, strconv.Itoa(elem))
+		}
+	}
+	return strings.Join(fields, ja3ElemSep)
+}
+
+// ja3Hash returns the hex-encoded MD5 digest of a JA3 string.
+func ja3Hash(ja3 string) string {
+	sum := md5.Sum([]byte(ja3))
+	return hex.EncodeToString(sum[:])
+}
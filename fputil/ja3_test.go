@@ -0,0 +1,131 @@
+package fp_test
+
+import (
+	"testing"
+
+	fp "github.com/cloudflare/mitmengine/fputil"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+func TestRequestFingerprintJA3(t *testing.T) {
+	var tests = []struct {
+		in   string
+		ja3  string
+		hash string
+	}{
+		{"::::::", "0,,,,", "2432bebf06532faf89aae784a9aae4ef"},
+		{"303:c02f,c030,9c:0,b,ff01,17,12,5,a,d,32,10,2b:17,18:0::", "771,49199-49200-156,0-11-65281-23-18-5-10-13-50-16-43,23-24,0", "328db42e9762a3a453a432a8930dba86"},
+		{"303:2a2a,c02f,c030,9c:0,b,ff01,17,12,5,a,d,32,10,2b,3a3a:fafa,17,18:0::", "771,49199-49200-156,0-11-65281-23-18-5-10-13-50-16-43,23-24,0", "328db42e9762a3a453a432a8930dba86"},
+		{"300:a,5:::::compr", "768,10-5,,,", "7c49ffce91aefa9b139a763790fdf2c4"},
+	}
+	for _, test := range tests {
+		fingerprint, err := fp.NewRequestFingerprint(test.in)
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.ja3, fingerprint.JA3())
+		testutil.Equals(t, test.hash, fingerprint.JA3Hash())
+	}
+}
+
+func TestRequestSignatureJA3(t *testing.T) {
+	var tests = []struct {
+		in     string
+		ja3    []string
+		hashes []string
+	}{
+		{
+			"303:c02f,c030,9c:0,b,ff01,17,12,5,a,d,32,10,2b:17,18:0:*:*",
+			[]string{"771,49199-49200-156,0-11-65281-23-18-5-10-13-50-16-43,23-24,0"},
+			[]string{"328db42e9762a3a453a432a8930dba86"},
+		},
+		{
+			"7f1c:1301:2b,33:1d:0::",
+			[]string{"32540,4865,43-51,29,0"},
+			[]string{"ef978889875e02c4487ce5006b26d123"},
+		},
+		{
+			"302,303,303:2f,?35:?0,a:17:0::",
+			[]string{
+				"770,47,0-10,23,0",
+				"770,47,10,23,0",
+				"770,47-53,0-10,23,0",
+				"770,47-53,10,23,0",
+				"771,47,0-10,23,0",
+				"771,47,10,23,0",
+				"771,47-53,0-10,23,0",
+				"771,47-53,10,23,0",
+			},
+			[]string{
+				"3b705944bfdbb4057c735c71b400e7fc",
+				"3be30a28099ad9ab525ba5c051dbdb8d",
+				"431594c2b2cc6a18d058b288fb2cb958",
+				"6cff3c1d35e316900e77345d51d1805d",
+				"70480eae2167e2c27b8402e6331b371f",
+				"75405ff1480d2bffb7e6333f03d76170",
+				"889f174a7a8bae4ed50846012fdb6455",
+				"9d4f07e4acfc046cb81642e98b8b21e0",
+			},
+		},
+	}
+	for _, test := range tests {
+		signature, err := fp.NewRequestSignature(test.in)
+		testutil.Ok(t, err)
+		ja3, err := signature.JA3()
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.ja3, ja3)
+		hashes, err := signature.JA3Hashes()
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.hashes, hashes)
+	}
+}
+
+func TestRequestSignatureJA3Errors(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out error
+	}{
+		{":2f:0:17:0::", fp.ErrorSignatureNotOrdered},
+		{"303:*2f:0:17:0::", fp.ErrorSignatureNotOrdered},
+		{"303:2f:~0,a:17:0::", fp.ErrorSignatureNotOrdered},
+		{"303:?1,?2,?3,?4,?5,?6,?7,?8,?9,?a,?b,?c,?d:?0,?1,?2,?3,?4,?5,?6,?7,?8,?9,?a,?b,?c:17:0::", fp.ErrorTooManyJA3},
+	}
+	for _, test := range tests {
+		signature, err := fp.NewRequestSignature(test.in)
+		testutil.Ok(t, err)
+		_, err = signature.JA3Hashes()
+		testutil.Equals(t, test.out, err)
+	}
+}
+
+func TestNewRequestFingerprintFromJA3(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out string
+	}{
+		{"0,,,,", "::::::"},
+		{"771,49199-49200-156,0-11-65281-23-18-5-10-13-50-16-43,23-24,0", "303:c02f,c030,9c:0,b,ff01,17,12,5,a,d,32,10,2b:17,18:0::"},
+		{"771,2570-4865,2570-0-10,2570-29,0\n", "303:a0a,1301:a0a,0,a:a0a,1d:0::"},
+		{"2,,,,", "200::::::"},
+	}
+	for _, test := range tests {
+		fingerprint, err := fp.NewRequestFingerprintFromJA3(test.in)
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.out, fingerprint.String())
+	}
+}
+
+func TestNewRequestFingerprintFromJA3Errors(t *testing.T) {
+	var tests = []string{
+		"",
+		"771,47,0,23",
+		"771,47,0,23,0,0",
+		"770.5,47,0,23,0",
+		"1,47,0,23,0",
+		"771,47--53,0,23,0",
+		"771,65536,0,23,0",
+		"771,2f,0,23,0",
+	}
+	for _, test := range tests {
+		_, err := fp.NewRequestFingerprintFromJA3(test)
+		testutil.Assert(t, err != nil, "expected error for ja3 '%s'", test)
+	}
+}
@@ -0,0 +1,120 @@
+package fp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// JA4 strings have the format
+// 	<a>_<b>_<c>
+// where <a> is the protocol ('t' for TCP), the two-character maximum TLS
+// version, 'd' if the client sent a server name indication and 'i' otherwise,
+// the two-digit counts of ciphers and extensions, and the first and last
+// characters of the first ALPN value ('00' if there is none). <b> is a
+// truncated SHA-256 hash of the sorted ciphers, and <c> is a truncated SHA-256
+// hash of the sorted extensions excluding the server name and ALPN
+// extensions, followed by the signature algorithms in their original order if
+// there are any. GREASE values are not included.
+// Source:
+//  - https://github.com/FoxIO-LLC/ja4/blob/main/technical_details/JA4.md
+
+const (
+	ja4FieldSep  string = "_"
+	ja4ElemSep   string = ","
+	ja4HashLen   int    = 12
+	ja4MaxCount  int    = 99
+	ja4EmptyHash string = "000000000000"
+)
+
+// ja4Versions maps TLS versions to their JA4 representation.
+var ja4Versions = map[Version]string{
+	VersionSSL2:  "s2",
+	VersionSSL3:  "s3",
+	VersionTLS10: "10",
+	VersionTLS11: "11",
+	VersionTLS12: "12",
+	VersionTLS13: "13",
+}
+
+// JA4 returns the JA4 string for the fingerprint.
+func (a RequestFingerprint) JA4() string {
+	version, ok := ja4Versions[a.MaxVersion()]
+	if !ok {
+		version = "00"
+	}
+	sni := "i"
+	if a.SNI {
+		sni = "d"
+	}
+	var ciphers, extensions []string
+	for _, elem := range a.Cipher {
+		if !IsGrease(elem) {
+			ciphers = append(ciphers, fmt.Sprintf("%04x", elem))
+		}
+	}
+	extensionCount := 0
+	for _, elem := range a.Extension {
+		if IsGrease(elem) {
+			continue
+		}
+		extensionCount++
+		if elem != int(extensionServerName) && elem != int(extensionALPN) {
+			extensions = append(extensions, fmt.Sprintf("%04x", elem))
+		}
+	}
+	sort.Strings(ciphers)
+	sort.Strings(extensions)
+	extensionHash := ja4Hash(extensions, ja4ElemSep)
+	if len(extensions) > 0 && len(a.SigAlg) > 0 {
+		var sigAlgs []string
+		for _, elem := range a.SigAlg {
+			if !IsGrease(elem) {
+				sigAlgs = append(sigAlgs, fmt.Sprintf("%04x", elem))
+			}
+		}
+		extensionHash = ja4Hash([]string{strings.Join(extensions, ja4ElemSep), strings.Join(sigAlgs, ja4ElemSep)}, ja4FieldSep)
+	}
+	prefix := fmt.Sprintf("t%s%s%02d%02d%s", version, sni, ja4Count(len(ciphers)), ja4Count(extensionCount), ja4ALPN(a.ALPN))
+	return strings.Join([]string{prefix, ja4Hash(ciphers, ja4ElemSep), extensionHash}, ja4FieldSep)
+}
+
+// ja4Count caps a count at the largest value that fits in two digits.
+func ja4Count(count int) int {
+	if count > ja4MaxCount {
+		return ja4MaxCount
+	}
+	return count
+}
+
+// ja4ALPN returns the first and last characters of the first ALPN value, or
+// the first and last hex digits of the value if either character is not
+// alphanumeric.
+func ja4ALPN(alpn StringList) string {
+	if len(alpn) == 0 || len(alpn[0]) == 0 {
+		return "00"
+	}
+	first, last := alpn[0][0], alpn[0][len(alpn[0])-1]
+	if !isAlphanumeric(first) || !isAlphanumeric(last) {
+		encoded := hex.EncodeToString([]byte(alpn[0]))
+		return encoded[:1] + encoded[len(encoded)-1:]
+	}
+	return string([]byte{first, last})
+}
+
+// isAlphanumeric returns true if the byte is an ASCII letter or digit.
+func isAlphanumeric(b byte) bool {
+	return ('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
+
+// ja4Hash returns the truncated hex-encoded SHA-256 digest of the list joined
+// by sep, or all zeros if the list is empty.
+func ja4Hash(list []string, sep string) string {
+	if len(list) == 0 {
+		return ja4EmptyHash
+	}
+	sum := sha256.Sum256([]byte(strings.Join(list, sep)))
+	return hex.EncodeToString(sum[:])[:ja4HashLen]
+}
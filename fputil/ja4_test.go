@@ -0,0 +1,57 @@
+package fp_test
+
+import (
+	"testing"
+
+	fp "github.com/cloudflare/mitmengine/fputil"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+func TestRequestFingerprintJA4(t *testing.T) {
+	var tests = []struct {
+		in   string
+		alpn fp.StringList
+		out  string
+	}{
+		{"::::::", nil, "t00i000000_000000000000_000000000000"},
+		{"303:c02f,c030,9c:0,b,ff01,17,12,5,a,d,32,10,2b:17,18:0::", fp.StringList{"h2", "http/1.1"}, "t12d0311h2_13bac7b83268_cd6db516bf2f"},
+		{"300:a,5:::::compr", nil, "ts3i020000_ecbe99380d04_000000000000"},
+		// grease values are not counted, and sni and alpn extensions are counted but not hashed
+		{"304:1a1a,c02b,1301:2a2a,a,0,10,2b:1d:0::", fp.StringList{"http/1.1"}, "t13d0204h1_777cda164f4b_b0ac53b37fa7"},
+		// non-alphanumeric alpn characters are hex-encoded
+		{"304:1301,c02b:a,2b:1d:0::", fp.StringList{"\x00ab\xff"}, "t13i02020f_777cda164f4b_b0ac53b37fa7"},
+		{"304:1301,c02b:a,2b:1d:0::", fp.StringList{"x"}, "t13i0202xx_777cda164f4b_b0ac53b37fa7"},
+		// signature algorithms are hashed with the extensions in their original order
+		{"304:1301,c02b:a,d,2b:1d:0:::403,804", nil, "t13i020300_777cda164f4b_fbabbea27ee8"},
+	}
+	for _, test := range tests {
+		fingerprint, err := fp.NewRequestFingerprint(test.in)
+		testutil.Ok(t, err)
+		fingerprint.ALPN = test.alpn
+		testutil.Equals(t, test.out, fingerprint.JA4())
+	}
+}
+
+// TestRequestFingerprintJA4Reference checks the JA4 string of the Chrome
+// client hello published with the JA4 technical details, with its ciphers and
+// extensions in the order Chrome sent them and with GREASE values added.
+// Source:
+//  - https://github.com/FoxIO-LLC/ja4/blob/main/technical_details/JA4.md
+func TestRequestFingerprintJA4Reference(t *testing.T) {
+	fingerprint, err := fp.NewRequestFingerprint("303:" +
+		"a0a,1301,1302,1303,c02b,c02f,c02c,c030,cca9,cca8,c013,c014,9c,9d,2f,35:" +
+		"2a2a,0,17,ff01,a,b,23,10,5,d,12,33,2d,2b,1b,4469,15,3a3a:" +
+		"4a4a,1d,17,18:0:::" +
+		"403,804,401,503,805,501,806,601:" +
+		"h2,http/1.1:" +
+		"5a5a,304,303:" +
+		"4a4a,1d")
+	testutil.Ok(t, err)
+	testutil.Equals(t, "t13d1516h2_8daaf6152771_e5627efa2ab1", fingerprint.JA4())
+}
+
+func TestParseClientHelloJA4(t *testing.T) {
+	fingerprint, err := fp.ParseClientHello(mustDecodeHex(t, goClientHelloRecord))
+	testutil.Ok(t, err)
+	testutil.Equals(t, "t12d0311h2_13bac7b83268_a92c7c6a82fe", fingerprint.JA4())
+}
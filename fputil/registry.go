@@ -0,0 +1,283 @@
+package fp
+
+import (
+	"embed"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Names of the tables in a registry, which are also the keys of a registry
+// JSON file.
+const (
+	RegistryCipherSuites     string = "cipher_suites"
+	RegistryNamedGroups      string = "named_groups"
+	RegistryExtensions       string = "extensions"
+	RegistrySignatureSchemes string = "signature_schemes"
+)
+
+// registryTables lists the tables of a registry.
+var registryTables = []string{
+	RegistryCipherSuites,
+	RegistryNamedGroups,
+	RegistryExtensions,
+	RegistrySignatureSchemes,
+}
+
+// registryDir is the directory of the default registry files.
+const registryDir string = "registry"
+
+// registryFiles contains the default registry, with a CSV file for each table.
+//
+//go:embed registry/*.csv
+var registryFiles embed.FS
+
+// A RegistryEntry describes a TLS parameter value, such as a cipher suite.
+// Grade is the security grade of the value, if known. Pfs and Aead are only set
+// for cipher suites, and are true if the cipher suite has perfect forward
+// secrecy or uses authenticated encryption with associated data.
+type RegistryEntry struct {
+	Value int
+	Name  string
+	Grade Grade
+	Pfs   bool
+	Aead  bool
+}
+
+// A Registry contains the cipher suites, named groups, extensions, and
+// signature schemes that are known to a CipherCheck, with their names and
+// security grades.
+type Registry struct {
+	tables map[string]map[int]RegistryEntry
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{tables: make(map[string]map[int]RegistryEntry)}
+}
+
+// DefaultRegistry returns a new registry with the default entries, which are
+// used by NewCipherCheck. Cipher suites are graded following the interception
+// paper, and named groups with less than 112-bit security or explicit curves
+// are graded C.
+func DefaultRegistry() *Registry {
+	a := NewRegistry()
+	for _, table := range registryTables {
+		file, err := registryFiles.Open(path.Join(registryDir, table+".csv"))
+		if err != nil {
+			panic(err) // the embedded files always exist
+		}
+		err = a.ReadCSV(table, file)
+		file.Close()
+		if err != nil {
+			panic(err) // the embedded files are always valid
+		}
+	}
+	return a
+}
+
+// Add adds an entry to a table of the registry, replacing any entry with the
+// same value.
+func (a *Registry) Add(table string, entry RegistryEntry) error {
+	if !isRegistryTable(table) {
+		return fmt.Errorf("unknown registry table: '%s'", table)
+	}
+	if a.tables[table] == nil {
+		a.tables[table] = make(map[int]RegistryEntry)
+	}
+	a.tables[table][entry.Value] = entry
+	return nil
+}
+
+// Lookup returns the entry with the value in a table of the registry, and
+// whether there is one.
+func (a *Registry) Lookup(table string, value int) (RegistryEntry, bool) {
+	entry, ok := a.tables[table][value]
+	return entry, ok
+}
+
+// Entries returns the entries in a table of the registry, sorted by value.
+func (a *Registry) Entries(table string) []RegistryEntry {
+	entries := make([]RegistryEntry, 0, len(a.tables[table]))
+	for _, entry := range a.tables[table] {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Value < entries[j].Value })
+	return entries
+}
+
+// ReadCSV adds the entries in a CSV file to a table of the registry, replacing
+// any entries with the same values. The file is in the format of the IANA TLS
+// parameter registries: the first row names the columns, and each other row
+// is an entry. The Value column is required, and contains a decimal or hex
+// value, or a list of hex bytes such as "0xC0,0x2B". Rows with a range of
+// values are skipped. The name is read from the Description, Name, or
+// Extension Name column, and the optional Grade, PFS, and AEAD columns contain
+// a grade as returned by Grade.String and booleans such as "Y" and "N". Other
+// columns and lines starting with '#' are ignored. If any row is invalid, no
+// entries are added.
+func (a *Registry) ReadCSV(table string, r io.Reader) error {
+	if !isRegistryTable(table) {
+		return fmt.Errorf("unknown registry table: '%s'", table)
+	}
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("registry %s: %s", table, err)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("registry %s: missing header", table)
+	}
+	columns := make(map[string]int)
+	for idx, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = idx
+	}
+	if _, ok := columns["value"]; !ok {
+		return fmt.Errorf("registry %s: missing Value column", table)
+	}
+	var entries []RegistryEntry
+	for _, record := range records[1:] {
+		field := func(names ...string) string {
+			for _, name := range names {
+				if idx, ok := columns[name]; ok {
+					return strings.TrimSpace(record[idx])
+				}
+			}
+			return ""
+		}
+		entry, ok, err := newRegistryEntry(field("value"), field("description", "name", "extension name"), field("grade"))
+		if err != nil {
+			return fmt.Errorf("registry %s: %s", table, err)
+		}
+		if !ok {
+			continue
+		}
+		if entry.Pfs, err = parseRegistryBool(field("pfs")); err != nil {
+			return fmt.Errorf("registry %s: %s", table, err)
+		}
+		if entry.Aead, err = parseRegistryBool(field("aead")); err != nil {
+			return fmt.Errorf("registry %s: %s", table, err)
+		}
+		entries = append(entries, entry)
+	}
+	for _, entry := range entries {
+		a.Add(table, entry)
+	}
+	return nil
+}
+
+// ReadJSON adds the entries in a JSON file to the registry, replacing any
+// entries with the same values. The file contains an object that maps table
+// names to lists of entries, such as
+//
+//	{"cipher_suites": [{"value": "0xC0,0x2B", "name": "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "grade": "A", "pfs": true, "aead": true}]}
+//
+// where values are numbers or strings in the format described for ReadCSV. If
+// any entry is invalid, no entries are added.
+func (a *Registry) ReadJSON(r io.Reader) error {
+	var tables map[string][]struct {
+		Value interface{} `json:"value"`
+		Name  string      `json:"name"`
+		Grade string      `json:"grade"`
+		Pfs   bool        `json:"pfs"`
+		Aead  bool        `json:"aead"`
+	}
+	if err := json.NewDecoder(r).Decode(&tables); err != nil {
+		return fmt.Errorf("registry: %s", err)
+	}
+	entries := make(map[string][]RegistryEntry)
+	for table, elems := range tables {
+		if !isRegistryTable(table) {
+			return fmt.Errorf("unknown registry table: '%s'", table)
+		}
+		for _, elem := range elems {
+			var value string
+			switch elemValue := elem.Value.(type) {
+			case string:
+				value = elemValue
+			case float64:
+				value = strconv.FormatFloat(elemValue, 'f', -1, 64)
+			default:
+				return fmt.Errorf("registry %s: invalid value: '%v'", table, elem.Value)
+			}
+			entry, ok, err := newRegistryEntry(value, elem.Name, elem.Grade)
+			if err != nil {
+				return fmt.Errorf("registry %s: %s", table, err)
+			}
+			if !ok {
+				continue
+			}
+			entry.Pfs = elem.Pfs
+			entry.Aead = elem.Aead
+			entries[table] = append(entries[table], entry)
+		}
+	}
+	for table, tableEntries := range entries {
+		for _, entry := range tableEntries {
+			a.Add(table, entry)
+		}
+	}
+	return nil
+}
+
+// newRegistryEntry returns a registry entry parsed from a value, name, and
+// grade, and false if the value is a range of values.
+func newRegistryEntry(value string, name string, grade string) (RegistryEntry, bool, error) {
+	var entry RegistryEntry
+	if strings.Contains(value, "-") {
+		return entry, false, nil
+	}
+	parts := strings.Split(value, ",")
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		var u uint64
+		var err error
+		if strings.HasPrefix(part, "0x") || strings.HasPrefix(part, "0X") {
+			u, err = strconv.ParseUint(part[2:], 16, 16)
+		} else {
+			u, err = strconv.ParseUint(part, 10, 16)
+		}
+		if err != nil || (len(parts) > 1 && u > 0xff) {
+			return entry, false, fmt.Errorf("invalid value: '%s'", value)
+		}
+		entry.Value = entry.Value<<8 | int(u)
+	}
+	entry.Name = name
+	if err := entry.Grade.Parse(grade); err != nil {
+		return entry, false, err
+	}
+	return entry, true, nil
+}
+
+// parseRegistryBool parses a boolean such as "Y", "N", or "true", where an
+// empty string is false.
+func parseRegistryBool(s string) (bool, error) {
+	switch s {
+	case "", "N", "n":
+		return false, nil
+	case "Y", "y":
+		return true, nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean: '%s'", s)
+	}
+	return b, nil
+}
+
+// isRegistryTable returns true if the table is one of the tables of a
+// registry.
+func isRegistryTable(table string) bool {
+	for _, elem := range registryTables {
+		if table == elem {
+			return true
+		}
+	}
+	return false
+}
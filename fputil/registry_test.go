@@ -0,0 +1,159 @@
+package fp_test
+
+import (
+	"strings"
+	"testing"
+
+	fp "github.com/cloudflare/mitmengine/fputil"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+func TestDefaultRegistry(t *testing.T) {
+	var tests = []struct {
+		table string
+		value int
+		out   fp.RegistryEntry
+	}{
+		{fp.RegistryCipherSuites, 0xC02B, fp.RegistryEntry{Value: 0xC02B, Name: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", Grade: fp.GradeA, Pfs: true, Aead: true}},
+		{fp.RegistryCipherSuites, 0x0004, fp.RegistryEntry{Value: 0x0004, Name: "TLS_RSA_WITH_RC4_128_MD5", Grade: fp.GradeC}},
+		{fp.RegistryCipherSuites, 0x1301, fp.RegistryEntry{Value: 0x1301, Name: "TLS_AES_128_GCM_SHA256", Grade: fp.GradeA, Pfs: true, Aead: true}},
+		{fp.RegistryCipherSuites, 0x009C, fp.RegistryEntry{Value: 0x009C, Name: "TLS_RSA_WITH_AES_128_GCM_SHA256", Grade: fp.GradeB, Aead: true}},
+		{fp.RegistryCipherSuites, 0x010080, fp.RegistryEntry{Value: 0x010080, Name: "SSL2_RC4_128_WITH_MD5", Grade: fp.GradeF}},
+		{fp.RegistryNamedGroups, 0x0001, fp.RegistryEntry{Value: 0x0001, Name: "sect163k1", Grade: fp.GradeC}},
+		{fp.RegistryNamedGroups, 0x11EC, fp.RegistryEntry{Value: 0x11EC, Name: "X25519MLKEM768", Grade: fp.GradeA}},
+		{fp.RegistryExtensions, 0x000F, fp.RegistryEntry{Value: 0x000F, Name: "heartbeat"}},
+		{fp.RegistryExtensions, 0xFF01, fp.RegistryEntry{Value: 0xFF01, Name: "renegotiation_info"}},
+		{fp.RegistrySignatureSchemes, 0x0403, fp.RegistryEntry{Value: 0x0403, Name: "ecdsa_secp256r1_sha256"}},
+	}
+
+	registry := fp.DefaultRegistry()
+	for _, test := range tests {
+		actual, ok := registry.Lookup(test.table, test.value)
+		testutil.Assert(t, ok, "missing %s entry %x", test.table, test.value)
+		testutil.Equals(t, test.out, actual)
+	}
+	_, ok := registry.Lookup(fp.RegistryCipherSuites, 0x1304)
+	testutil.Assert(t, !ok, "unexpected cipher suite entry 1304")
+}
+
+// Check that every TLS 1.3 cipher suite in the default registry has perfect
+// forward secrecy, since TLS 1.3 only negotiates the key exchange separately
+func TestDefaultRegistryTLS13Pfs(t *testing.T) {
+	check := fp.NewCipherCheckFromRegistry(fp.DefaultRegistry())
+	var count int
+	for _, entry := range fp.DefaultRegistry().Entries(fp.RegistryCipherSuites) {
+		if entry.Value>>8 != 0x13 {
+			continue
+		}
+		count++
+		testutil.Assert(t, entry.Pfs, "expected %s to be pfs", entry.Name)
+		testutil.Assert(t, entry.Aead, "expected %s to be aead", entry.Name)
+		testutil.Assert(t, check.IsFirstPfs(fp.IntList{entry.Value}), "expected %s to be first pfs", entry.Name)
+	}
+	testutil.Equals(t, 3, count)
+}
+
+func TestRegistryReadCSV(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out []fp.RegistryEntry
+	}{
+		{"Value,Description\n", []fp.RegistryEntry{}},
+		{
+			"Value,Description,DTLS-OK,Recommended,Reference\n" +
+				"\"0x00,0x00\",TLS_NULL_WITH_NULL_NULL,Y,N,[RFC5246]\n" +
+				"\"0x00,0x1C-1D\",Reserved to avoid conflicts with SSLv3,,,[RFC5246]\n" +
+				"\"0x13,0x01\",TLS_AES_128_GCM_SHA256,Y,Y,[RFC8446]\n",
+			[]fp.RegistryEntry{{Value: 0x0000, Name: "TLS_NULL_WITH_NULL_NULL"}, {Value: 0x1301, Name: "TLS_AES_128_GCM_SHA256"}},
+		},
+		{
+			"# comment\nvalue,name,grade,pfs,aead\n0x1304,TLS_AES_128_CCM_SHA256,A,true,Y\n49195,TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,B,,\n",
+			[]fp.RegistryEntry{{Value: 0x1304, Name: "TLS_AES_128_CCM_SHA256", Grade: fp.GradeA, Pfs: true, Aead: true}, {Value: 0xC02B, Name: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", Grade: fp.GradeB}},
+		},
+		{"Value,Extension Name\n65281,renegotiation_info\n", []fp.RegistryEntry{{Value: 0xFF01, Name: "renegotiation_info"}}},
+	}
+
+	for _, test := range tests {
+		registry := fp.NewRegistry()
+		testutil.Ok(t, registry.ReadCSV(fp.RegistryCipherSuites, strings.NewReader(test.in)))
+		testutil.Equals(t, test.out, registry.Entries(fp.RegistryCipherSuites))
+	}
+}
+
+func TestRegistryReadCSVError(t *testing.T) {
+	var tests = []struct {
+		table string
+		in    string
+	}{
+		{"ciphers", "Value,Description\n"},
+		{fp.RegistryCipherSuites, ""},
+		{fp.RegistryCipherSuites, "Description\nTLS_NULL_WITH_NULL_NULL\n"},
+		{fp.RegistryCipherSuites, "Value,Description\n0x1301,TLS_AES_128_GCM_SHA256\nzz,bad\n"},
+		{fp.RegistryCipherSuites, "Value,Description\n\"0x13,0x101\",bad\n"},
+		{fp.RegistryCipherSuites, "Value,Grade\n0x1301,D\n"},
+		{fp.RegistryCipherSuites, "Value,PFS\n0x1301,maybe\n"},
+		{fp.RegistryCipherSuites, "Value,Description\n0x1301\n"},
+	}
+
+	for _, test := range tests {
+		registry := fp.NewRegistry()
+		err := registry.ReadCSV(test.table, strings.NewReader(test.in))
+		testutil.Assert(t, err != nil, "expected error for %q", test.in)
+		testutil.Equals(t, []fp.RegistryEntry{}, registry.Entries(fp.RegistryCipherSuites))
+	}
+}
+
+func TestRegistryReadJSON(t *testing.T) {
+	in := `{
+		"cipher_suites": [
+			{"value": "0x13,0x04", "name": "TLS_AES_128_CCM_SHA256", "grade": "A", "pfs": true, "aead": true},
+			{"value": "0x0004", "name": "TLS_RSA_WITH_RC4_128_MD5", "grade": "C"}
+		],
+		"named_groups": [{"value": 4588, "name": "X25519MLKEM768", "grade": "A"}]
+	}`
+
+	registry := fp.NewRegistry()
+	testutil.Ok(t, registry.ReadJSON(strings.NewReader(in)))
+	testutil.Equals(t, []fp.RegistryEntry{
+		{Value: 0x0004, Name: "TLS_RSA_WITH_RC4_128_MD5", Grade: fp.GradeC},
+		{Value: 0x1304, Name: "TLS_AES_128_CCM_SHA256", Grade: fp.GradeA, Pfs: true, Aead: true},
+	}, registry.Entries(fp.RegistryCipherSuites))
+	testutil.Equals(t, []fp.RegistryEntry{
+		{Value: 0x11EC, Name: "X25519MLKEM768", Grade: fp.GradeA},
+	}, registry.Entries(fp.RegistryNamedGroups))
+
+	for _, in := range []string{
+		`{"ciphers": [{"value": 1}]}`,
+		`{"cipher_suites": [{"value": true}]}`,
+		`{"cipher_suites": [{"value": 1, "grade": "D"}]}`,
+		`{"cipher_suites": [`,
+	} {
+		testutil.Assert(t, fp.NewRegistry().ReadJSON(strings.NewReader(in)) != nil, "expected error for %s", in)
+	}
+}
+
+func TestNewCipherCheckFromRegistry(t *testing.T) {
+	registry := fp.DefaultRegistry()
+	testutil.Ok(t, registry.ReadJSON(strings.NewReader(`{
+		"cipher_suites": [
+			{"value": "0x13,0x04", "name": "TLS_AES_128_CCM_SHA256", "grade": "A", "pfs": true, "aead": true},
+			{"value": "0xC0,0x2B", "name": "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "grade": "C", "pfs": true, "aead": true}
+		],
+		"named_groups": [{"value": "0x001D", "name": "x25519", "grade": "F"}]
+	}`)))
+
+	check := fp.NewCipherCheckFromRegistry(registry)
+	testutil.Equals(t, registry, check.Registry())
+	testutil.Equals(t, fp.GradeA, check.Grade(fp.IntList{0x1304}))
+	testutil.Equals(t, true, check.IsFirstPfs(fp.IntList{0x1304}))
+	testutil.Equals(t, fp.GradeC, check.Grade(fp.IntList{0xC02B}))
+	testutil.Equals(t, fp.GradeEmpty, fp.GlobalCipherCheck.Grade(fp.IntList{0x1304}))
+	testutil.Equals(t, fp.GradeA, fp.GlobalCipherCheck.Grade(fp.IntList{0xC02B}))
+
+	fingerprint, err := fp.NewRequestFingerprint("303:1304:ff01:1d,17:0::")
+	testutil.Ok(t, err)
+	testutil.Equals(t, []fp.SecurityFinding{
+		{Name: fp.FindingWeakCurve, Field: "curve", Values: fp.IntList{0x1d}, Grade: fp.GradeF},
+	}, check.Assess(fingerprint).Findings)
+	testutil.Equals(t, []fp.SecurityFinding(nil), fp.GlobalCipherCheck.Assess(fingerprint).Findings)
+}
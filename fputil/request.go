@@ -9,14 +9,20 @@ import (
 )
 
 // Client request signature and fingerprint strings have the format
-// 	<version>:<cipher>:<extension>:<curve>:<ecpointfmt>:<header>:<quirk>
+// 	<version>:<cipher>:<extension>:<curve>:<ecpointfmt>:<header>:<quirk>[:<sigalg>[:<alpn>[:<supportedversion>[:<keyshare>]]]]
+//
+// Fields after <quirk> were added in later versions of the format and may be
+// omitted, so that records written in an older version still load. An omitted
+// field is empty in a fingerprint and matches anything in a signature, and
+// trailing fields with these default values are omitted from the string
+// representation.
 //
 // For fingerprints the parts have the formats
 // <version>:
 //	<vers>
-// <cipher>, <extension>, <curve>, <ecpointfmt>:
+// <cipher>, <extension>, <curve>, <ecpointfmt>, <sigalg>, <supportedversion>, <keyshare>:
 //	<int-list>
-// <header>, <quirk>:
+// <header>, <quirk>, <alpn>:
 //	<str-list>
 // where <vers> is a TLS version ('', '2.0', '3.0', '3.1', '3.2', '3.3', '3.4')
 // <int-list> is a comma-separated list of hex-encoded ints, and <str-list> is
@@ -25,9 +31,9 @@ import (
 // and for signatures the parts have the formats
 // <version>:
 //      [<exp>|<min>,<exp>,<max>]
-// <cipher>, <extension>, <curve>, <ecpointfmt>:
+// <cipher>, <extension>, <curve>, <ecpointfmt>, <sigalg>, <supportedversion>, <keyshare>:
 //	[*~][<[!?+]int-list>]
-// <header>, <quirk>:
+// <header>, <quirk>, <alpn>:
 //	[*~][<[!?+]str-list>]
 // where items in enclosed in square brackets are optional,
 // <exp> is the expected TLS version, <min> is the minimum TLS version, <max> is the maximum TLS version,
@@ -45,9 +51,10 @@ import (
 //	   ''  means the item is required (default)
 
 const (
-	requestFieldCount int    = 7
-	requestFieldSep   string = ":"
-	fieldElemSep      string = ","
+	requestFieldCount    int    = 11
+	minRequestFieldCount int    = 7
+	requestFieldSep      string = ":"
+	fieldElemSep         string = ","
 )
 const (
 	flagAnyItems byte = '*'
@@ -67,6 +74,19 @@ type RequestFingerprint struct {
 	EcPointFmt IntList
 	Header     StringList
 	Quirk      StringList
+	SigAlg     IntList
+	ALPN       StringList
+
+	// SupportedVersion lists the versions in the supported_versions
+	// extension, which TLS 1.3 clients use in place of the legacy version.
+	SupportedVersion IntList
+
+	// KeyShare lists the groups of the key shares sent by the client.
+	KeyShare IntList
+
+	// SNI is true if the client sent a server name indication. When the
+	// fingerprint is parsed from a string, it is derived from the extensions.
+	SNI bool
 }
 
 // NewRequestFingerprint is a wrapper around RequestFingerprint.Parse
@@ -78,9 +98,9 @@ func NewRequestFingerprint(s string) (RequestFingerprint, error) {
 
 // Parse a fingerprint from a string and return an error on failure.
 func (a *RequestFingerprint) Parse(s string) error {
-	fields := strings.Split(s, requestFieldSep)
-	if len(fields) != requestFieldCount {
-		return fmt.Errorf("bad request field count '%s': exp %d, got %d", s, requestFieldCount, len(fields))
+	fields, err := splitRequestFields(s, "")
+	if err != nil {
+		return err
 	}
 	fieldIdx := 0
 	if err := a.Version.Parse(fields[fieldIdx]); err != nil {
@@ -110,12 +130,29 @@ func (a *RequestFingerprint) Parse(s string) error {
 	if err := a.Quirk.Parse(fields[fieldIdx]); err != nil {
 		return err
 	}
+	fieldIdx++
+	if err := a.SigAlg.Parse(fields[fieldIdx]); err != nil {
+		return err
+	}
+	fieldIdx++
+	if err := a.ALPN.Parse(fields[fieldIdx]); err != nil {
+		return err
+	}
+	fieldIdx++
+	if err := a.SupportedVersion.Parse(fields[fieldIdx]); err != nil {
+		return err
+	}
+	fieldIdx++
+	if err := a.KeyShare.Parse(fields[fieldIdx]); err != nil {
+		return err
+	}
+	a.SNI = a.Extension.Set().Has(int(extensionServerName))
 	return nil
 }
 
 // String returns a string representation of the fingerprint.
 func (a RequestFingerprint) String() string {
-	return strings.Join([]string{
+	return joinRequestFields([]string{
 		a.Version.String(),
 		a.Cipher.String(),
 		a.Extension.String(),
@@ -123,7 +160,78 @@ func (a RequestFingerprint) String() string {
 		a.EcPointFmt.String(),
 		a.Header.String(),
 		a.Quirk.String(),
-	}, requestFieldSep)
+		a.SigAlg.String(),
+		a.ALPN.String(),
+		a.SupportedVersion.String(),
+		a.KeyShare.String(),
+	}, "")
+}
+
+// Normalize returns a copy of the fingerprint prepared for matching against
+// request signatures. GREASE values are removed from the cipher, extension,
+// curve, supported version, and key share fields and recorded as the "grease"
+// quirk instead, and any header in badHeaderSet, which browsers never send, is
+// recorded as the "badhdr" quirk. The lists of the fingerprint itself are not
+// modified, so a fingerprint can be normalized by concurrent goroutines.
+func (a RequestFingerprint) Normalize(badHeaderSet StringSet) RequestFingerprint {
+	// clip the quirk list so that appends copy it
+	a.Quirk = a.Quirk[:len(a.Quirk):len(a.Quirk)]
+
+	var hasGreaseCipher, hasGreaseExtension, hasGreaseCurve, hasGreaseVersion, hasGreaseKeyShare bool
+	a.Cipher, hasGreaseCipher = removeGrease(a.Cipher)
+	a.Extension, hasGreaseExtension = removeGrease(a.Extension)
+	a.Curve, hasGreaseCurve = removeGrease(a.Curve)
+	a.SupportedVersion, hasGreaseVersion = removeGrease(a.SupportedVersion)
+	a.KeyShare, hasGreaseKeyShare = removeGrease(a.KeyShare)
+	if hasGreaseCipher || hasGreaseExtension || hasGreaseCurve || hasGreaseVersion || hasGreaseKeyShare {
+		a.Quirk = append(a.Quirk, "grease")
+	}
+
+	for _, elem := range a.Header {
+		if badHeaderSet[elem] {
+			a.Quirk = append(a.Quirk, "badhdr")
+			break
+		}
+	}
+	return a
+}
+
+// removeGrease returns a new list without the GREASE values in the list, and
+// true if any were found.
+func removeGrease(list IntList) (IntList, bool) {
+	var kept IntList
+	hasGrease := false
+	for _, elem := range list {
+		if IsGrease(elem) {
+			hasGrease = true
+		} else {
+			kept = append(kept, elem)
+		}
+	}
+	return kept, hasGrease
+}
+
+// splitRequestFields splits a fingerprint or signature string into fields,
+// padding a string in an older version of the format with the default value
+// for the omitted fields.
+func splitRequestFields(s string, defaultValue string) ([]string, error) {
+	fields := strings.Split(s, requestFieldSep)
+	if len(fields) < minRequestFieldCount || len(fields) > requestFieldCount {
+		return nil, fmt.Errorf("bad request field count '%s': exp %d to %d, got %d", s, minRequestFieldCount, requestFieldCount, len(fields))
+	}
+	for len(fields) < requestFieldCount {
+		fields = append(fields, defaultValue)
+	}
+	return fields, nil
+}
+
+// joinRequestFields joins the fields of a fingerprint or signature string,
+// omitting trailing optional fields that are equal to the default value.
+func joinRequestFields(fields []string, defaultValue string) string {
+	for len(fields) > minRequestFieldCount && fields[len(fields)-1] == defaultValue {
+		fields = fields[:len(fields)-1]
+	}
+	return strings.Join(fields, requestFieldSep)
 }
 
 // A RequestSignature represents a set of client request fingerprints. Many TLS/HTTPS
@@ -136,6 +244,14 @@ type RequestSignature struct {
 	EcPointFmt IntSignature
 	Header     StringSignature
 	Quirk      StringSignature
+	SigAlg     IntSignature
+	ALPN       StringSignature
+
+	// SupportedVersion and KeyShare are signatures on the corresponding
+	// fingerprint fields. A signature that requires version 0x0304 in
+	// SupportedVersion requires TLS 1.3 support.
+	SupportedVersion IntSignature
+	KeyShare         IntSignature
 
 	// non-exported fields
 	pfs         bool
@@ -178,9 +294,9 @@ func NewRequestSignature(s string) (RequestSignature, error) {
 
 // Parse a signature from a string and return an error on failure.
 func (a *RequestSignature) Parse(s string) error {
-	fields := strings.Split(s, requestFieldSep)
-	if len(fields) != requestFieldCount {
-		return fmt.Errorf("bad request field count '%s': exp %d, got %d", s, requestFieldCount, len(fields))
+	fields, err := splitRequestFields(s, string(flagAnyItems))
+	if err != nil {
+		return err
 	}
 	fieldIdx := 0
 	if err := a.Version.Parse(fields[fieldIdx]); err != nil {
@@ -210,6 +326,22 @@ func (a *RequestSignature) Parse(s string) error {
 	if err := a.Quirk.Parse(fields[fieldIdx]); err != nil {
 		return err
 	}
+	fieldIdx++
+	if err := a.SigAlg.Parse(fields[fieldIdx]); err != nil {
+		return err
+	}
+	fieldIdx++
+	if err := a.ALPN.Parse(fields[fieldIdx]); err != nil {
+		return err
+	}
+	fieldIdx++
+	if err := a.SupportedVersion.Parse(fields[fieldIdx]); err != nil {
+		return err
+	}
+	fieldIdx++
+	if err := a.KeyShare.Parse(fields[fieldIdx]); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -222,6 +354,12 @@ func (a *RequestSignature) Grade() Grade {
 	return a.grade
 }
 
+// ExplainGrade returns the security grade for the request signature, with the
+// factors that contribute to it.
+func (a *RequestSignature) ExplainGrade() GradeExplanation {
+	return GlobalCipherCheck.ExplainGrade(a.Cipher.OrderedList)
+}
+
 // IsPfs returns true if the request signature has perfect forward secrecy.
 func (a *RequestSignature) IsPfs() bool {
 	if !a.pfsCached {
@@ -261,15 +399,15 @@ func (a *VersionSignature) Parse(s string) error {
 	}
 	// sanity check
 	if a.Min != VersionEmpty {
-		if a.Exp != VersionEmpty && a.Min > a.Exp {
+		if a.Exp != VersionEmpty && a.Exp.Less(a.Min) {
 			return fmt.Errorf("version: Min > Exp")
 		}
-		if a.Max != VersionEmpty && a.Min > a.Max {
+		if a.Max != VersionEmpty && a.Max.Less(a.Min) {
 			return fmt.Errorf("version: Min > Max")
 		}
 	}
 	if a.Exp != VersionEmpty {
-		if a.Max != VersionEmpty && a.Exp > a.Max {
+		if a.Max != VersionEmpty && a.Max.Less(a.Exp) {
 			return fmt.Errorf("version: Exp > Max")
 		}
 	}
@@ -329,7 +467,7 @@ func (a *IntSignature) Parse(s string) error {
 		case flagOptional, flagUnlikely, flagExcluded:
 			v = v[1:]
 		}
-		elem64bit, err := strconv.ParseUint(v, 16, 16)
+		elem64bit, err := strconv.ParseUint(v, 16, 24)
 		elem := int(elem64bit)
 		if err != nil {
 			return err
@@ -420,7 +558,7 @@ func (a *StringSignature) Parse(s string) error {
 
 // Returns a string representation of the signature.
 func (a RequestSignature) String() string {
-	return strings.Join([]string{
+	return joinRequestFields([]string{
 		a.Version.String(),
 		a.Cipher.String(),
 		a.Extension.String(),
@@ -428,7 +566,11 @@ func (a RequestSignature) String() string {
 		a.EcPointFmt.String(),
 		a.Header.String(),
 		a.Quirk.String(),
-	}, requestFieldSep)
+		a.SigAlg.String(),
+		a.ALPN.String(),
+		a.SupportedVersion.String(),
+		a.KeyShare.String(),
+	}, string(flagAnyItems))
 }
 
 // Return a string representation of the version signature.
@@ -530,6 +672,10 @@ func (a RequestSignature) Merge(b RequestSignature) (merged RequestSignature) {
 	merged.EcPointFmt = a.EcPointFmt.Merge(b.EcPointFmt)
 	merged.Header = a.Header.Merge(b.Header)
 	merged.Quirk = a.Quirk.Merge(b.Quirk)
+	merged.SigAlg = a.SigAlg.Merge(b.SigAlg)
+	merged.ALPN = a.ALPN.Merge(b.ALPN)
+	merged.SupportedVersion = a.SupportedVersion.Merge(b.SupportedVersion)
+	merged.KeyShare = a.KeyShare.Merge(b.KeyShare)
 	merged.pfsCached = false
 	merged.gradeCached = false
 	return
@@ -539,17 +685,17 @@ func (a RequestSignature) Merge(b RequestSignature) (merged RequestSignature) {
 func (a VersionSignature) Merge(b VersionSignature) (merged VersionSignature) {
 	merged = a
 	if a.Exp != VersionEmpty {
-		if b.Exp == VersionEmpty || b.Exp < a.Exp {
+		if b.Exp == VersionEmpty || b.Exp.Less(a.Exp) {
 			merged.Exp = b.Exp
 		}
 	}
 	if a.Min != VersionEmpty {
-		if b.Min == VersionEmpty || b.Min < a.Min {
+		if b.Min == VersionEmpty || b.Min.Less(a.Min) {
 			merged.Min = b.Min
 		}
 	}
 	if a.Max != VersionEmpty {
-		if b.Max == VersionEmpty || b.Max > a.Max {
+		if b.Max == VersionEmpty || a.Max.Less(b.Max) {
 			merged.Max = b.Max
 		}
 	}
@@ -727,7 +873,7 @@ func (a RequestSignature) Match(fingerprint RequestFingerprint) (Match, int) {
 }
 
 // MatchMap returns (1) a map of the match results of the fingerprint against the signature,
-// and (2) the count of overlapping cipher, extension, curve, and ecpointfmt values.
+// and (2) the count of overlapping cipher, extension, curve, ecpointfmt, and sigalg values.
 // The second value helps a caller deduce the closest matching record in the case there is no "MatchPossible" match.
 func (a RequestSignature) MatchMap(fingerprint RequestFingerprint) (map[string]Match, int) {
 	matchMap := make(map[string]Match)
@@ -744,20 +890,101 @@ func (a RequestSignature) MatchMap(fingerprint RequestFingerprint) (map[string]M
 	similarity += matchCount
 	matchMap["header"] = a.Header.Match(fingerprint.Header)
 	matchMap["quirk"] = a.Quirk.Match(fingerprint.Quirk)
+	if fingerprint.hasSigAlg() {
+		matchMap["sigalg"], matchCount = a.SigAlg.Match(fingerprint.SigAlg)
+		similarity += matchCount
+	} else {
+		matchMap["sigalg"] = MatchPossible
+	}
+	if fingerprint.hasALPN() {
+		matchMap["alpn"] = a.ALPN.Match(fingerprint.ALPN)
+	} else {
+		matchMap["alpn"] = MatchPossible
+	}
+	if fingerprint.hasSupportedVersion() {
+		matchMap["supportedversion"], _ = a.SupportedVersion.Match(fingerprint.SupportedVersion)
+	} else {
+		matchMap["supportedversion"] = MatchPossible
+	}
+	if fingerprint.hasKeyShare() {
+		matchMap["keyshare"], _ = a.KeyShare.Match(fingerprint.KeyShare)
+	} else {
+		matchMap["keyshare"] = MatchPossible
+	}
 	return matchMap, similarity
 }
 
+// hasSigAlg returns false if the fingerprint does not record the signature
+// algorithms sent by the client, as for a fingerprint in an older version of
+// the format: the signature algorithms extension is present but the list is
+// empty.
+func (a RequestFingerprint) hasSigAlg() bool {
+	return len(a.SigAlg) > 0 || !a.Extension.Set().Has(int(extensionSignatureAlgorithms))
+}
+
+// hasALPN returns false if the fingerprint does not record the application
+// protocols offered by the client, in the same way as hasSigAlg.
+func (a RequestFingerprint) hasALPN() bool {
+	return len(a.ALPN) > 0 || !a.Extension.Set().Has(int(extensionALPN))
+}
+
+// hasSupportedVersion returns false if the fingerprint does not record the
+// versions in the supported_versions extension, in the same way as hasSigAlg.
+func (a RequestFingerprint) hasSupportedVersion() bool {
+	return len(a.SupportedVersion) > 0 || !a.Extension.Set().Has(int(extensionSupportedVersions))
+}
+
+// hasKeyShare returns false if the fingerprint does not record the key share
+// groups, in the same way as hasSigAlg.
+func (a RequestFingerprint) hasKeyShare() bool {
+	return len(a.KeyShare) > 0 || !a.Extension.Set().Has(int(extensionKeyShare))
+}
+
+// MaxVersion returns the highest version the client supports: the highest
+// known version in the supported_versions extension if it was sent, and the
+// legacy version otherwise. If the fingerprint does not record the
+// supported versions, the legacy version is returned.
+func (a RequestFingerprint) MaxVersion() Version {
+	max := VersionEmpty
+	for _, elem := range a.SupportedVersion {
+		var version Version
+		if err := version.Parse(strconv.FormatUint(uint64(elem), 16)); err != nil || version.IsGrease() {
+			continue // skip unknown and GREASE versions
+		}
+		if max.Less(version) {
+			max = version
+		}
+	}
+	if max == VersionEmpty {
+		return a.Version
+	}
+	return max
+}
+
+// RequiresTLS13 returns true if the signature only matches clients that
+// support TLS 1.3.
+func (a RequestSignature) RequiresTLS13() bool {
+	return a.SupportedVersion.RequiredSet.Has(int(VersionTLS13))
+}
+
+// IsVersionDowngrade returns true if the signature requires TLS 1.3 support,
+// but the fingerprint does not offer TLS 1.3. It returns false if the
+// fingerprint does not record the supported versions.
+func (a RequestSignature) IsVersionDowngrade(fingerprint RequestFingerprint) bool {
+	return a.RequiresTLS13() && fingerprint.hasSupportedVersion() && fingerprint.MaxVersion().Less(VersionTLS13)
+}
+
 // Match a version against the version signature.
 // Returns MatchImpossible if no match is possible, MatchUnlikely if the match
 // is possible with an unlikely configuration, and MatchPossible otherwise.
 func (a VersionSignature) Match(version Version) Match {
-	if a.Min != VersionEmpty && version < a.Min {
+	if a.Min != VersionEmpty && version.Less(a.Min) {
 		return MatchImpossible
 	}
-	if a.Max != VersionEmpty && version > a.Max {
+	if a.Max != VersionEmpty && a.Max.Less(version) {
 		return MatchImpossible
 	}
-	if a.Exp != VersionEmpty && version < a.Exp {
+	if a.Exp != VersionEmpty && version.Less(a.Exp) {
 		return MatchUnlikely
 	}
 	return MatchPossible
@@ -24,6 +24,7 @@ var (
 		ExcludedSet: make(fp.StringSet),
 		RequiredSet: make(fp.StringSet),
 	}
+	anyIntSig, _ = fp.NewIntSignature("*")
 )
 
 func TestNewRequestFingerprint(t *testing.T) {
@@ -32,6 +33,9 @@ func TestNewRequestFingerprint(t *testing.T) {
 		out fp.RequestFingerprint
 	}{
 		{"::::::", fp.RequestFingerprint{}},
+		{":::::::", fp.RequestFingerprint{}},
+		{"::d:::::403,804", fp.RequestFingerprint{Extension: fp.IntList{0xd}, SigAlg: fp.IntList{0x403, 0x804}}},
+		{"::10::::::h2,http/1.1", fp.RequestFingerprint{Extension: fp.IntList{0x10}, ALPN: fp.StringList{"h2", "http/1.1"}}},
 	}
 	for _, test := range tests {
 		fingerprint, err := fp.NewRequestFingerprint(test.in)
@@ -46,12 +50,54 @@ func TestRequestFingerprintString(t *testing.T) {
 		out string
 	}{
 		{fp.RequestFingerprint{}, "::::::"},
+		{fp.RequestFingerprint{SigAlg: fp.IntList{0x403, 0x804}}, ":::::::403,804"},
+		{fp.RequestFingerprint{ALPN: fp.StringList{"h2", "http/1.1"}}, "::::::::h2,http/1.1"},
 	}
 	for _, test := range tests {
 		testutil.Equals(t, test.out, test.in.String())
 	}
 }
 
+func TestRequestFingerprintNormalize(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out string
+	}{
+		{"303:c02b,c02f:0,a:1d:0:host,via:", "303:c02b,c02f:0,a:1d:0:host,via:badhdr"},
+		{"303:a0a,c02b:1a1a,0:2a2a,1d:0:host:", "303:c02b:0:1d:0:host:grease"},
+		{"303:c02b:0:1d:0:host::::3a3a,304:4a4a,1d", "303:c02b:0:1d:0:host:grease:::304:1d"},
+	}
+	badHeaderSet := fp.StringList{"via"}.Set()
+	for _, test := range tests {
+		fingerprint, err := fp.NewRequestFingerprint(test.in)
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.out, fingerprint.Normalize(badHeaderSet).String())
+		// the fingerprint itself is unchanged
+		testutil.Equals(t, test.in, fingerprint.String())
+	}
+}
+
+func TestRequestFingerprintMaxVersion(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out fp.Version
+	}{
+		{"::::::", fp.VersionEmpty},
+		{"303::::::", fp.VersionTLS12},
+		{"303::2b:::::::304,303", fp.VersionTLS13},
+		{"303::2b:::::::303,302", fp.VersionTLS12},
+		// grease versions are skipped, and drafts come after TLS 1.2
+		{"303::2b:::::::fafa,7f17,303", fp.Version(0x7f17)},
+		{"303::2b:::::::7f1c,304,fb1a", fp.VersionTLS13},
+		{"303::2b:::::::fafa", fp.VersionTLS12},
+	}
+	for _, test := range tests {
+		fingerprint, err := fp.NewRequestFingerprint(test.in)
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.out, fingerprint.MaxVersion())
+	}
+}
+
 func TestNewRequestSignature(t *testing.T) {
 	var tests = []struct {
 		str string
@@ -65,6 +111,11 @@ func TestNewRequestSignature(t *testing.T) {
 			EcPointFmt: emptyIntSig,
 			Header:     emptyStringSig,
 			Quirk:      emptyStringSig,
+			SigAlg:     anyIntSig,
+			ALPN:       anyStringSig,
+
+			SupportedVersion: anyIntSig,
+			KeyShare:         anyIntSig,
 		}},
 	}
 	for _, test := range tests {
@@ -87,6 +138,11 @@ func TestRequestSignatureString(t *testing.T) {
 			EcPointFmt: emptyIntSig,
 			Header:     emptyStringSig,
 			Quirk:      emptyStringSig,
+			SigAlg:     anyIntSig,
+			ALPN:       anyStringSig,
+
+			SupportedVersion: anyIntSig,
+			KeyShare:         anyIntSig,
 		}, "::::::"},
 	}
 	for _, test := range tests {
@@ -102,6 +158,10 @@ func TestRequestSignatureMerge(t *testing.T) {
 	}{
 		{"::::::", "::::::", "::::::"},
 		{":*:*:*:*:*:*", ":*:*:*:*:*:*", ":*:*:*:*:*:*"},
+		{":::::::403,804", ":::::::403,804", ":::::::403,804"},
+		{":::::::403,804", "::::::", "::::::"},
+		{":::::::", ":::::::", ":::::::"},
+		{"::::::::h2,http/1.1", "::::::::http/1.1", "::::::::?h2,http/1.1"},
 	}
 	for _, test := range tests {
 		signature1, err := fp.NewRequestSignature(test.in1)
@@ -186,6 +246,21 @@ func TestRequestSignatureMatch(t *testing.T) {
 	}{
 		{"::::::", "::::::", fp.MatchPossible},
 		{":*:*:*:*:*:*", "::::::", fp.MatchPossible},
+		{":*:*:*:*:*:*", ":::::::403", fp.MatchPossible},
+		{":*:*:*:*:*:*:403,?804", ":::::::403", fp.MatchPossible},
+		{":*:*:*:*:*:*:403,?804", ":::::::804,403", fp.MatchImpossible},
+		{":*:*:*:*:*:*:403,?804", "::::::", fp.MatchImpossible},
+		// fingerprints with the signature algorithms extension but no
+		// signature algorithms do not record them, so they are not checked
+		{":*:*:*:*:*:*:403,?804", "::d::::", fp.MatchPossible},
+		{":*:*:*:*:*:*:*:h2,http/1.1", "::10::::::h2,http/1.1", fp.MatchPossible},
+		{":*:*:*:*:*:*:*:h2,http/1.1", "::10::::::http/1.1", fp.MatchImpossible},
+		{":*:*:*:*:*:*:*:h2,http/1.1", "::::::", fp.MatchImpossible},
+		{":*:*:*:*:*:*:*:h2,http/1.1", "::10::::", fp.MatchPossible},
+		{":*:*:*:*:*:*:*:*:~304,?303:*", "::2b:::::::304,303:1d", fp.MatchPossible},
+		{":*:*:*:*:*:*:*:*:~304,?303:*", "::2b:::::::303", fp.MatchImpossible},
+		{":*:*:*:*:*:*:*:*:*:1d", "::2b,33:::::::304:17", fp.MatchImpossible},
+		{":*:*:*:*:*:*:*:*:~304,?303:1d", "::2b,33::::", fp.MatchPossible},
 	}
 	for _, test := range tests {
 		signature, err := fp.NewRequestSignature(test.in1)
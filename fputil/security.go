@@ -0,0 +1,139 @@
+package fp
+
+// TLS extension types used when assessing a client hello.
+// Source:
+//  - https://www.iana.org/assignments/tls-extensiontype-values/tls-extensiontype-values.xhtml
+const (
+	extensionHeartbeat         int = 0x000f
+	extensionRenegotiationInfo int = 0xff01
+)
+
+// EC point formats used when assessing a client hello.
+// Source:
+//  - https://tools.ietf.org/html/rfc8422#section-5.1.2
+const (
+	pointFormatUncompressed int = 0x00
+)
+
+// Names of security findings.
+const (
+	FindingBrokenCipher        string = "broken_cipher"
+	FindingKnownAttackCipher   string = "known_attack_cipher"
+	FindingWeakVersion         string = "weak_version"
+	FindingWeakCurve           string = "weak_curve"
+	FindingCompressedPointFmt  string = "compressed_ecpointfmt"
+	FindingHeartbeat           string = "heartbeat"
+	FindingNoRenegotiationInfo string = "no_renegotiation_info"
+)
+
+// A SecurityFinding is a security weakness of a request fingerprint. Field is
+// the fingerprint field that the finding is about, Values lists the values in
+// that field that cause the finding, if any, and Grade is the weakest security
+// grade that a request with the finding can have.
+type SecurityFinding struct {
+	Name   string
+	Field  string
+	Values IntList
+	Grade  Grade
+}
+
+// A SecurityAssessment lists the security weaknesses of a request
+// fingerprint. WeakCiphers lists the advertised cipher suites that are
+// vulnerable to a known attack or trivially broken, and Grade is the merged
+// grade of the findings, or GradeEmpty if there are none.
+type SecurityAssessment struct {
+	WeakCiphers IntList
+	Findings    []SecurityFinding
+	Grade       Grade
+}
+
+// Assess returns the security assessment of the request fingerprint, following
+// the categories of weaknesses in the interception paper. Cipher suites and
+// versions that are trivially broken are graded F, and those with known
+// attacks are graded C. Named groups are graded as in the registry, and are
+// weak if graded C or F, which by default are the elliptic curves with less
+// than 112-bit security or explicit parameters. The heartbeat extension
+// (Heartbleed) and a missing renegotiation_info extension and signaling cipher
+// suite value (insecure renegotiation, unless only TLS 1.3 is offered) are
+// graded C. Compressed EC point formats, which are deprecated, are graded B.
+// GREASE values are never weak, so they are ignored.
+// Source:
+//  - https://jhalderm.com/pub/papers/interception-ndss17.pdf
+func (a CipherCheck) Assess(fingerprint RequestFingerprint) SecurityAssessment {
+	var assessment SecurityAssessment
+	var broken, knownAttack IntList
+	for _, cipher := range fingerprint.Cipher {
+		switch {
+		case a.gradeF.Has(cipher):
+			broken = append(broken, cipher)
+		case a.gradeC.Has(cipher):
+			knownAttack = append(knownAttack, cipher)
+		default:
+			continue
+		}
+		assessment.WeakCiphers = append(assessment.WeakCiphers, cipher)
+	}
+	if len(broken) > 0 {
+		assessment.add(SecurityFinding{Name: FindingBrokenCipher, Field: "cipher", Values: broken, Grade: GradeF})
+	}
+	if len(knownAttack) > 0 {
+		assessment.add(SecurityFinding{Name: FindingKnownAttackCipher, Field: "cipher", Values: knownAttack, Grade: GradeC})
+	}
+
+	if grade := fingerprint.Version.Grade(); grade >= GradeC {
+		assessment.add(SecurityFinding{Name: FindingWeakVersion, Field: "version", Values: IntList{int(fingerprint.Version)}, Grade: grade})
+	}
+
+	var weakCurves IntList
+	var curveGrade Grade
+	for _, curve := range fingerprint.Curve {
+		if grade := a.curveGrades[curve]; grade >= GradeC {
+			weakCurves = append(weakCurves, curve)
+			curveGrade = curveGrade.Merge(grade)
+		}
+	}
+	if len(weakCurves) > 0 {
+		assessment.add(SecurityFinding{Name: FindingWeakCurve, Field: "curve", Values: weakCurves, Grade: curveGrade})
+	}
+
+	var compressed IntList
+	for _, pointFmt := range fingerprint.EcPointFmt {
+		if pointFmt != pointFormatUncompressed {
+			compressed = append(compressed, pointFmt)
+		}
+	}
+	if len(compressed) > 0 {
+		assessment.add(SecurityFinding{Name: FindingCompressedPointFmt, Field: "ecpointfmt", Values: compressed, Grade: GradeB})
+	}
+
+	extensions := fingerprint.Extension.Set()
+	if extensions.Has(extensionHeartbeat) {
+		assessment.add(SecurityFinding{Name: FindingHeartbeat, Field: "extension", Values: IntList{extensionHeartbeat}, Grade: GradeC})
+	}
+	if len(fingerprint.Cipher) > 0 && !extensions.Has(extensionRenegotiationInfo) &&
+		!fingerprint.Cipher.Set().Has(tlsEmptyRenegotiationInfoSCSV) && !fingerprint.onlyTLS13() {
+		assessment.add(SecurityFinding{Name: FindingNoRenegotiationInfo, Field: "extension", Grade: GradeC})
+	}
+	return assessment
+}
+
+// add adds a finding to the assessment and merges its grade.
+func (a *SecurityAssessment) add(finding SecurityFinding) {
+	a.Findings = append(a.Findings, finding)
+	a.Grade = a.Grade.Merge(finding.Grade)
+}
+
+// onlyTLS13 returns true if the fingerprint only offers TLS 1.3 or its
+// drafts, which do not support renegotiation.
+func (a RequestFingerprint) onlyTLS13() bool {
+	if len(a.SupportedVersion) == 0 {
+		return false
+	}
+	for _, elem := range a.SupportedVersion {
+		version := Version(elem)
+		if version != VersionTLS13 && !version.IsDraft() && !version.IsExperimental() && !version.IsGrease() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package fp_test
+
+import (
+	"testing"
+
+	fp "github.com/cloudflare/mitmengine/fputil"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+func TestCipherCheckAssess(t *testing.T) {
+	var tests = []struct {
+		in          string
+		weakCiphers fp.IntList
+		findings    []fp.SecurityFinding
+		grade       fp.Grade
+	}{
+		{"::::::", nil, nil, fp.GradeEmpty},
+		{"303:c02b,c02f:ff01,a,b,d:1d,17:0:::403,804", nil, nil, fp.GradeEmpty},
+		{"303:c02b,c02f,ff:a,b,d:1d,17:0:::403,804", nil, nil, fp.GradeEmpty},
+		{"303:1301,c02b:2b:1d::::::304,303", nil, []fp.SecurityFinding{
+			{Name: fp.FindingNoRenegotiationInfo, Field: "extension", Grade: fp.GradeC},
+		}, fp.GradeC},
+		{"303:1301:2b:1d::::::304", nil, nil, fp.GradeEmpty},
+		{"303:1301:2b:1d::::::7f17,304", nil, nil, fp.GradeEmpty},
+		{"303:c02b,a,4,3:ff01::::", fp.IntList{0x4, 0x3}, []fp.SecurityFinding{
+			{Name: fp.FindingBrokenCipher, Field: "cipher", Values: fp.IntList{0x3}, Grade: fp.GradeF},
+			{Name: fp.FindingKnownAttackCipher, Field: "cipher", Values: fp.IntList{0x4}, Grade: fp.GradeC},
+		}, fp.GradeF},
+		{"300:c02b:ff01::::", nil, []fp.SecurityFinding{
+			{Name: fp.FindingWeakVersion, Field: "version", Values: fp.IntList{0x300}, Grade: fp.GradeC},
+		}, fp.GradeC},
+		{"303:c02b:ff01:17,1,3:0::", nil, []fp.SecurityFinding{
+			{Name: fp.FindingWeakCurve, Field: "curve", Values: fp.IntList{0x1, 0x3}, Grade: fp.GradeC},
+		}, fp.GradeC},
+		{"303:c02b:ff01:17:0,1,2::", nil, []fp.SecurityFinding{
+			{Name: fp.FindingCompressedPointFmt, Field: "ecpointfmt", Values: fp.IntList{0x1, 0x2}, Grade: fp.GradeB},
+		}, fp.GradeB},
+		{"303:c02b:ff01,f::::", nil, []fp.SecurityFinding{
+			{Name: fp.FindingHeartbeat, Field: "extension", Values: fp.IntList{0xf}, Grade: fp.GradeC},
+		}, fp.GradeC},
+	}
+
+	check := fp.NewCipherCheck()
+	for _, test := range tests {
+		fingerprint, err := fp.NewRequestFingerprint(test.in)
+		testutil.Ok(t, err)
+		assessment := check.Assess(fingerprint)
+		testutil.Equals(t, test.weakCiphers, assessment.WeakCiphers)
+		testutil.Equals(t, test.findings, assessment.Findings)
+		testutil.Equals(t, test.grade, assessment.Grade)
+	}
+}
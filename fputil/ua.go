@@ -2,6 +2,7 @@ package fp
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 
@@ -247,6 +248,21 @@ func (a UAVersionSignature) Match(fingerprint UAVersion) Match {
 	return MatchImpossible
 }
 
+// MajorBounds returns the lowest and highest major versions that the
+// signature can match, with math.MinInt32 and math.MaxInt32 for unbounded
+// ends. A version matches the signature if and only if its major version is
+// within these bounds.
+func (a UAVersionSignature) MajorBounds() (int, int) {
+	min, max := math.MinInt32, math.MaxInt32
+	if a.Min.Major != anyVersion {
+		min = a.Min.Major
+	}
+	if a.Max.Major != anyVersion {
+		max = a.Max.Major
+	}
+	return min, max
+}
+
 // minMerge returns the min value of two versions.
 func (a UAVersion) minMerge(b UAVersion) UAVersion {
 	if a.Major == anyVersion || b.Major == anyVersion {
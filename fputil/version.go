@@ -39,11 +39,73 @@ func (a *Version) Parse(s string) error {
 	case 0x0304:
 		*a = VersionTLS13
 	default:
-		return fmt.Errorf("invalid tls version: %s", s)
+		*a = Version(u)
+		if !a.IsDraft() && !a.IsExperimental() && !a.IsGrease() {
+			*a = VersionEmpty
+			return fmt.Errorf("invalid tls version: %s", s)
+		}
 	}
 	return nil
 }
 
+// IsDraft returns true if the version is a TLS 1.3 draft version.
+func (a Version) IsDraft() bool {
+	return a&0xff00 == versionDraft
+}
+
+// IsExperimental returns true if the version is an experimental TLS 1.3
+// version based on a draft.
+func (a Version) IsExperimental() bool {
+	return a&0xff00 == versionExperimental
+}
+
+// IsGrease returns true if the version is a GREASE value.
+func (a Version) IsGrease() bool {
+	return IsGrease(int(a))
+}
+
+// IsGrease returns true if the value is one of the GREASE values reserved for
+// cipher suites, extensions, named groups, versions, and other 16-bit client
+// hello fields, which have the form 0x?a?a with equal bytes.
+// Source:
+//  - https://tools.ietf.org/html/rfc8701
+func IsGrease(value int) bool {
+	return value&0x0f0f == 0x0a0a && value>>8 == value&0xff
+}
+
+// Draft returns the TLS 1.3 draft number of a draft or experimental version,
+// or zero for other versions.
+func (a Version) Draft() int {
+	if !a.IsDraft() && !a.IsExperimental() {
+		return 0
+	}
+	return int(a & 0x00ff)
+}
+
+// Less returns true if version a is older than version b. Draft and
+// experimental versions come after TLS 1.2 and before TLS 1.3, ordered by
+// draft number, and an experimental version comes after the draft it is
+// based on. GREASE values come before all other versions except VersionEmpty.
+func (a Version) Less(b Version) bool {
+	return a.rank() < b.rank()
+}
+
+// rank returns the position of the version in the ordering used by Less.
+func (a Version) rank() int {
+	switch {
+	case a == VersionEmpty:
+		return 0
+	case a.IsGrease():
+		return 1
+	case a.IsDraft():
+		return int(VersionTLS12)<<10 + a.Draft()<<1 + 1
+	case a.IsExperimental():
+		return int(VersionTLS12)<<10 + a.Draft()<<1 + 2
+	default:
+		return int(a) << 10
+	}
+}
+
 // String returns a string representation of the version
 func (a Version) String() string {
 	if a == VersionEmpty {
@@ -52,6 +114,14 @@ func (a Version) String() string {
 	return fmt.Sprintf("%x", uint16(a))
 }
 
+// ExplainGrade returns the security grade for the version, with the version as
+// its only factor.
+func (a Version) ExplainGrade() GradeExplanation {
+	var explanation GradeExplanation
+	explanation.Add(GradeFactor{Name: GradeFactorVersion, Value: a.String(), Grade: a.Grade()})
+	return explanation
+}
+
 // Grade returns a security grade for the version
 func (a Version) Grade() Grade {
 	switch a {
@@ -63,6 +133,12 @@ func (a Version) Grade() Grade {
 		return GradeB
 	case VersionSSL3:
 		return GradeC
+	}
+	switch {
+	case a.IsDraft(), a.IsExperimental():
+		return GradeA
+	case a.IsGrease():
+		return GradeEmpty
 	default:
 		return GradeF
 	}
@@ -75,6 +151,8 @@ func (a Version) Grade() Grade {
 //  - TLS1.1: https://www.ietf.org/rfc/rfc4346.txt
 //  - TLS1.2: https://www.ietf.org/rfc/rfc5246.txt
 //  - TLS1.3: https://tools.ietf.org/html/draft-ietf-tls-tls13-28#section-4.2.1
+//  - TLS1.3 drafts and experiments: https://github.com/tlswg/tls13-spec/wiki/Implementations
+//  - GREASE: https://tools.ietf.org/html/rfc8701
 const (
 	VersionEmpty Version = 0
 	VersionSSL2  Version = 0x0200 // 0x0002 on the wire, so let's swap here
@@ -83,4 +161,9 @@ const (
 	VersionTLS11 Version = 0x0302
 	VersionTLS12 Version = 0x0303
 	VersionTLS13 Version = 0x0304
+
+	// High bytes of TLS 1.3 draft and experimental versions. The low byte
+	// is the draft number, so 0x7f1c is draft 28.
+	versionDraft        Version = 0x7f00
+	versionExperimental Version = 0xfb00
 )
@@ -20,6 +20,7 @@ func TestVersionString(t *testing.T) {
 		{fp.VersionTLS12, "303"},
 		{fp.VersionTLS13, "304"},
 		{fp.Version(255), "ff"},
+		{fp.Version(0x7f1c), "7f1c"},
 	}
 
 	for _, test := range tests {
@@ -41,6 +42,9 @@ func TestVersionGrade(t *testing.T) {
 		{fp.VersionTLS12, fp.GradeA},
 		{fp.VersionTLS13, fp.GradeA},
 		{fp.Version(255), fp.GradeF},
+		{fp.Version(0x7f1c), fp.GradeA},
+		{fp.Version(0xfb1a), fp.GradeA},
+		{fp.Version(0x0a0a), fp.GradeEmpty},
 	}
 
 	for _, test := range tests {
@@ -48,3 +52,127 @@ func TestVersionGrade(t *testing.T) {
 		testutil.Equals(t, test.out, actual)
 	}
 }
+
+func TestVersionExplainGrade(t *testing.T) {
+	var tests = []struct {
+		in  fp.Version
+		out fp.GradeExplanation
+	}{
+		{fp.VersionEmpty, fp.GradeExplanation{}},
+		{fp.VersionSSL3, fp.GradeExplanation{Grade: fp.GradeC, Factors: []fp.GradeFactor{{Name: fp.GradeFactorVersion, Value: "300", Grade: fp.GradeC}}}},
+		{fp.VersionTLS12, fp.GradeExplanation{Grade: fp.GradeA, Factors: []fp.GradeFactor{{Name: fp.GradeFactorVersion, Value: "303", Grade: fp.GradeA}}}},
+		{fp.Version(0x0a0a), fp.GradeExplanation{}},
+	}
+
+	for _, test := range tests {
+		actual := test.in.ExplainGrade()
+		testutil.Equals(t, test.out, actual)
+	}
+}
+
+func TestNewVersion(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out fp.Version
+	}{
+		{"", fp.VersionEmpty},
+		{"2", fp.VersionSSL2},
+		{"200", fp.VersionSSL2},
+		{"303", fp.VersionTLS12},
+		{"304", fp.VersionTLS13},
+		{"7f1c", fp.Version(0x7f1c)},
+		{"fb1a", fp.Version(0xfb1a)},
+		{"1a1a", fp.Version(0x1a1a)},
+	}
+	for _, test := range tests {
+		actual, err := fp.NewVersion(test.in)
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.out, actual)
+	}
+	for _, in := range []string{"1", "305", "7e1c", "10000", "x"} {
+		_, err := fp.NewVersion(in)
+		testutil.Assert(t, err != nil, "expected error for version '%s'", in)
+	}
+}
+
+func TestVersionDraft(t *testing.T) {
+	var tests = []struct {
+		in           fp.Version
+		draft        int
+		experimental bool
+		grease       bool
+	}{
+		{fp.VersionTLS13, 0, false, false},
+		{fp.Version(0x7f1c), 28, false, false},
+		{fp.Version(0xfb1a), 26, true, false},
+		{fp.Version(0x3a3a), 0, false, true},
+	}
+	for _, test := range tests {
+		testutil.Equals(t, test.draft, test.in.Draft())
+		testutil.Equals(t, test.draft != 0 && !test.experimental, test.in.IsDraft())
+		testutil.Equals(t, test.experimental, test.in.IsExperimental())
+		testutil.Equals(t, test.grease, test.in.IsGrease())
+	}
+}
+
+func TestIsGrease(t *testing.T) {
+	var tests = []struct {
+		in  int
+		out bool
+	}{
+		{0x0a0a, true},
+		{0x1a1a, true},
+		{0xfafa, true},
+		{0x1a2a, false},
+		{0x3afa, false},
+		{0x0a0b, false},
+		{0x0303, false},
+	}
+	for _, test := range tests {
+		testutil.Equals(t, test.out, fp.IsGrease(test.in))
+		testutil.Equals(t, test.out, fp.Version(test.in).IsGrease())
+	}
+}
+
+func TestVersionLess(t *testing.T) {
+	// versions in increasing order
+	var versions = []fp.Version{
+		fp.VersionEmpty,
+		fp.Version(0x0a0a),
+		fp.VersionSSL2,
+		fp.VersionSSL3,
+		fp.VersionTLS10,
+		fp.VersionTLS11,
+		fp.VersionTLS12,
+		fp.Version(0x7f12),
+		fp.Version(0xfb12),
+		fp.Version(0x7f1a),
+		fp.Version(0xfb1a),
+		fp.Version(0x7f1c),
+		fp.VersionTLS13,
+	}
+	for i, a := range versions {
+		for j, b := range versions {
+			testutil.Assert(t, a.Less(b) == (i < j), "%s < %s: exp %v", a, b, i < j)
+		}
+	}
+}
+
+func TestVersionSignatureMatchDraft(t *testing.T) {
+	var tests = []struct {
+		signature string
+		version   fp.Version
+		out       fp.Match
+	}{
+		{"303,304,304", fp.Version(0x7f1c), fp.MatchUnlikely},
+		{"303,303,304", fp.Version(0x7f1c), fp.MatchPossible},
+		{"303", fp.Version(0x7f1c), fp.MatchImpossible},
+		{"303,7f17,304", fp.Version(0x7f12), fp.MatchUnlikely},
+		{"7f1c", fp.Version(0x7f1c), fp.MatchPossible},
+	}
+	for _, test := range tests {
+		signature, err := fp.NewVersionSignature(test.signature)
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.out, signature.Match(test.version))
+	}
+}
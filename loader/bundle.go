@@ -0,0 +1,216 @@
+package loader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Bundle manifests have the format
+// 	<sha256>  <file-name>
+// with one line for each file in the bundle, sorted by file name, where
+// <sha256> is the hex-encoded SHA-256 digest of the file contents. This is the
+// format written by sha256sum. The signature file contains the base64-encoded
+// ed25519 signature of the manifest.
+
+const (
+	// ManifestFileName is the default name of a bundle manifest.
+	ManifestFileName string = "manifest.txt"
+
+	// SignatureFileName is the default name of a bundle manifest signature.
+	SignatureFileName string = "manifest.txt.sig"
+
+	manifestSep string = "  "
+)
+
+var (
+	// ErrBadSignature indicates that a bundle manifest does not have a valid
+	// signature for the public key.
+	ErrBadSignature = errors.New("loader: bad manifest signature")
+
+	// ErrDigestMismatch indicates that a file does not match its digest in the
+	// bundle manifest.
+	ErrDigestMismatch = errors.New("loader: file does not match manifest digest")
+
+	// ErrNotInManifest indicates that a file is not listed in the bundle
+	// manifest.
+	ErrNotInManifest = errors.New("loader: file not in manifest")
+)
+
+// A BundleLoader loads a set of files together, so that either all of the
+// files are loaded from a consistent set or none of them are.
+type BundleLoader interface {
+	Loader
+	LoadBundle(fileNames []string) (map[string][]byte, error)
+}
+
+// A Manifest maps file names to the SHA-256 digests of their contents.
+type Manifest map[string][sha256.Size]byte
+
+// NewManifest returns a manifest for the files, given as a map of file names
+// to file contents.
+func NewManifest(files map[string][]byte) Manifest {
+	a := make(Manifest, len(files))
+	for fileName, contents := range files {
+		a[fileName] = sha256.Sum256(contents)
+	}
+	return a
+}
+
+// ParseManifest parses a manifest and returns an error on failure.
+func ParseManifest(data []byte) (Manifest, error) {
+	a := make(Manifest)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 {
+			continue
+		}
+		fields := strings.SplitN(line, manifestSep, 2)
+		if len(fields) != 2 || len(fields[1]) == 0 {
+			return nil, fmt.Errorf("invalid manifest line: '%s'", line)
+		}
+		digest, err := hex.DecodeString(fields[0])
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("invalid manifest digest: '%s'", fields[0])
+		}
+		if _, ok := a[fields[1]]; ok {
+			return nil, fmt.Errorf("duplicate manifest file: '%s'", fields[1])
+		}
+		var sum [sha256.Size]byte
+		copy(sum[:], digest)
+		a[fields[1]] = sum
+	}
+	return a, scanner.Err()
+}
+
+// Bytes returns the manifest in the manifest format.
+func (a Manifest) Bytes() []byte {
+	var fileNames []string
+	for fileName := range a {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+	var buf bytes.Buffer
+	for _, fileName := range fileNames {
+		sum := a[fileName]
+		buf.WriteString(hex.EncodeToString(sum[:]) + manifestSep + fileName + "\n")
+	}
+	return buf.Bytes()
+}
+
+// Verify returns an error if the file is not in the manifest or if its
+// contents do not match the digest in the manifest.
+func (a Manifest) Verify(fileName string, contents []byte) error {
+	sum, ok := a[fileName]
+	if !ok {
+		return fmt.Errorf("%s: %w", fileName, ErrNotInManifest)
+	}
+	if sha256.Sum256(contents) != sum {
+		return fmt.Errorf("%s: %w", fileName, ErrDigestMismatch)
+	}
+	return nil
+}
+
+// SignManifest returns the contents of the signature file for a manifest.
+func SignManifest(manifest []byte, privateKey ed25519.PrivateKey) []byte {
+	signature := ed25519.Sign(privateKey, manifest)
+	return []byte(base64.StdEncoding.EncodeToString(signature) + "\n")
+}
+
+// VerifyManifest parses a manifest and returns an error if the signature file
+// does not contain a valid signature of the manifest for the public key.
+func VerifyManifest(manifest []byte, signature []byte, publicKey ed25519.PublicKey) (Manifest, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil || len(publicKey) != ed25519.PublicKeySize || !ed25519.Verify(publicKey, manifest, decoded) {
+		return nil, ErrBadSignature
+	}
+	return ParseManifest(manifest)
+}
+
+// Verified implements interfaces Loader, BundleLoader, ContextLoader, and
+// BundleContextLoader by loading files from another Loader and verifying them
+// against a signed bundle manifest before returning them.
+type Verified struct {
+	loader        Loader
+	publicKey     ed25519.PublicKey
+	manifestName  string
+	signatureName string
+}
+
+// NewVerified creates a Verified loader that loads files from the loader and
+// verifies them against the manifest in ManifestFileName, which must be signed
+// by the private key for the public key in SignatureFileName.
+func NewVerified(loader Loader, publicKey ed25519.PublicKey) *Verified {
+	return &Verified{
+		loader:        loader,
+		publicKey:     publicKey,
+		manifestName:  ManifestFileName,
+		signatureName: SignatureFileName,
+	}
+}
+
+// LoadFile implements the LoadFile function specified in Loader interface, as
+// defined in loader.go. The file is only returned if it matches the signed
+// manifest.
+func (verified *Verified) LoadFile(fileName string) (io.ReadCloser, error) {
+	return verified.LoadFileContext(context.Background(), fileName)
+}
+
+// LoadFileContext implements the ContextLoader interface.
+func (verified *Verified) LoadFileContext(ctx context.Context, fileName string) (io.ReadCloser, error) {
+	files, err := verified.LoadBundleContext(ctx, []string{fileName})
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(files[fileName])), nil
+}
+
+// LoadBundle implements the BundleLoader interface. It loads and verifies the
+// signed manifest, and then loads each of the files. If the signature is not
+// valid or any of the files is not in the manifest or does not match its
+// digest, an error is returned and no files are returned.
+func (verified *Verified) LoadBundle(fileNames []string) (map[string][]byte, error) {
+	return verified.LoadBundleContext(context.Background(), fileNames)
+}
+
+// LoadBundleContext implements the BundleContextLoader interface.
+func (verified *Verified) LoadBundleContext(ctx context.Context, fileNames []string) (map[string][]byte, error) {
+	manifestData, err := readFile(ctx, verified.loader, verified.manifestName)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := readFile(ctx, verified.loader, verified.signatureName)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := VerifyManifest(manifestData, signature, verified.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte, len(fileNames))
+	for _, fileName := range fileNames {
+		if _, ok := manifest[fileName]; !ok {
+			return nil, fmt.Errorf("%s: %w", fileName, ErrNotInManifest)
+		}
+		contents, err := readFile(ctx, verified.loader, fileName)
+		if err != nil {
+			return nil, err
+		}
+		if err := manifest.Verify(fileName, contents); err != nil {
+			return nil, err
+		}
+		files[fileName] = contents
+	}
+	return files, nil
+}
@@ -0,0 +1,129 @@
+package loader_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+
+	"github.com/cloudflare/mitmengine"
+	"github.com/cloudflare/mitmengine/loader"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+var testBundleSeed = bytes.Repeat([]byte{1}, ed25519.SeedSize)
+
+// newSignedBundle returns the files with a manifest of the files and its
+// signature.
+func newSignedBundle(files map[string][]byte) testutil.MapLoader {
+	bundle := make(testutil.MapLoader)
+	for fileName, contents := range files {
+		bundle[fileName] = contents
+	}
+	manifest := loader.NewManifest(files).Bytes()
+	bundle[loader.ManifestFileName] = manifest
+	bundle[loader.SignatureFileName] = loader.SignManifest(manifest, ed25519.NewKeyFromSeed(testBundleSeed))
+	return bundle
+}
+
+func testBundleFiles() map[string][]byte {
+	return map[string][]byte{
+		"browser.txt":   []byte("1:72:2:3:10.14:1:|303:c02b,c02f:0,a:1d:0:*:*|:0:0\n"),
+		"mitm.txt":      []byte(""),
+		"badheader.txt": []byte("x-bluecoat-via\n"),
+	}
+}
+
+func TestManifest(t *testing.T) {
+	manifest := loader.NewManifest(map[string][]byte{"b.txt": []byte("b"), "a.txt": []byte("a")})
+	data := manifest.Bytes()
+	testutil.Equals(t, "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb  a.txt\n3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d  b.txt\n", string(data))
+	parsed, err := loader.ParseManifest(data)
+	testutil.Ok(t, err)
+	testutil.Equals(t, manifest, parsed)
+	testutil.Ok(t, parsed.Verify("a.txt", []byte("a")))
+	testutil.Assert(t, errors.Is(parsed.Verify("a.txt", []byte("b")), loader.ErrDigestMismatch), "expected digest mismatch")
+	testutil.Assert(t, errors.Is(parsed.Verify("c.txt", []byte("c")), loader.ErrNotInManifest), "expected missing file")
+}
+
+func TestParseManifestErrors(t *testing.T) {
+	var tests = []string{
+		"ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb a.txt",
+		"ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb  ",
+		"ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48  a.txt",
+		"za978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb  a.txt",
+		"ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb  a.txt\nca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb  a.txt",
+	}
+	for _, test := range tests {
+		_, err := loader.ParseManifest([]byte(test))
+		testutil.Assert(t, err != nil, "expected error for manifest '%s'", test)
+	}
+}
+
+func TestVerifiedLoadBundle(t *testing.T) {
+	publicKey := ed25519.NewKeyFromSeed(testBundleSeed).Public().(ed25519.PublicKey)
+	otherKey := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{2}, ed25519.SeedSize)).Public().(ed25519.PublicKey)
+	fileNames := []string{"browser.txt", "mitm.txt", "badheader.txt"}
+	var tests = []struct {
+		modify    func(testutil.MapLoader)
+		publicKey ed25519.PublicKey
+		err       error
+	}{
+		{func(testutil.MapLoader) {}, publicKey, nil},
+		{func(testutil.MapLoader) {}, otherKey, loader.ErrBadSignature},
+		{func(bundle testutil.MapLoader) { bundle["mitm.txt"] = []byte("tampered") }, publicKey, loader.ErrDigestMismatch},
+		{func(bundle testutil.MapLoader) {
+			bundle[loader.ManifestFileName] = loader.NewManifest(map[string][]byte{"browser.txt": bundle["browser.txt"]}).Bytes()
+		}, publicKey, loader.ErrBadSignature},
+		{func(bundle testutil.MapLoader) { bundle[loader.SignatureFileName] = []byte("not base64") }, publicKey, loader.ErrBadSignature},
+	}
+	for _, test := range tests {
+		bundle := newSignedBundle(testBundleFiles())
+		test.modify(bundle)
+		files, err := loader.NewVerified(bundle, test.publicKey).LoadBundle(fileNames)
+		if test.err != nil {
+			testutil.Assert(t, errors.Is(err, test.err), "expected error '%v', got '%v'", test.err, err)
+			testutil.Assert(t, files == nil, "files returned with error")
+			continue
+		}
+		testutil.Ok(t, err)
+		testutil.Equals(t, testBundleFiles(), files)
+	}
+
+	// a partial bundle is rejected
+	partial := testBundleFiles()
+	delete(partial, "mitm.txt")
+	bundle := newSignedBundle(partial)
+	_, err := loader.NewVerified(bundle, publicKey).LoadBundle(fileNames)
+	testutil.Assert(t, errors.Is(err, loader.ErrNotInManifest), "expected error for partial bundle, got '%v'", err)
+
+	// an unsigned bundle is rejected
+	bundle = newSignedBundle(testBundleFiles())
+	delete(bundle, loader.SignatureFileName)
+	_, err = loader.NewVerified(bundle, publicKey).LoadFile("browser.txt")
+	testutil.Assert(t, err != nil, "expected error for unsigned bundle")
+}
+
+func TestProcessorConfigVerified(t *testing.T) {
+	publicKey := ed25519.NewKeyFromSeed(testBundleSeed).Public().(ed25519.PublicKey)
+	bundle := newSignedBundle(testBundleFiles())
+	config := mitmengine.Config{
+		BrowserFileName:   "browser.txt",
+		MitmFileName:      "mitm.txt",
+		BadHeaderFileName: "badheader.txt",
+		Loader:            loader.NewVerified(bundle, publicKey),
+	}
+	a, err := mitmengine.NewProcessor(&config)
+	testutil.Ok(t, err)
+	previous := a.Snapshot()
+	testutil.Equals(t, 1, previous.BrowserDatabase.Len())
+
+	// a tampered file is rejected, even though the other files are valid
+	bundle["badheader.txt"] = []byte("x-bluecoat-via\nx-forwarded-for\n")
+	testutil.Assert(t, a.Load(&config) != nil, "expected error for tampered bundle")
+	testutil.Equals(t, previous, a.Snapshot())
+
+	// a tampered initial load is rejected instead of loading empty databases
+	_, err = mitmengine.NewProcessor(&config)
+	testutil.Assert(t, errors.Is(err, loader.ErrDigestMismatch), "expected error for tampered bundle, got '%v'", err)
+}
@@ -0,0 +1,252 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// A SourceLoader is a BundleLoader that loads files from one of several
+// sources and reports the name of the source that the files came from.
+type SourceLoader interface {
+	BundleLoader
+	LoadBundleSource(fileNames []string) (map[string][]byte, string, error)
+}
+
+// A Committer is a Loader that is told when the files that it loaded have been
+// accepted, for example because they were parsed successfully.
+type Committer interface {
+	Loader
+	Commit(files map[string][]byte) error
+}
+
+// Commit tells the loader that the files have been accepted if it implements
+// Committer, and does nothing otherwise.
+func Commit(loader Loader, files map[string][]byte) error {
+	if committer, ok := loader.(Committer); ok {
+		return committer.Commit(files)
+	}
+	return nil
+}
+
+// Cache implements interfaces Loader, BundleLoader, ContextLoader,
+// BundleContextLoader, and Committer by loading files from another Loader and
+// saving a copy of them in a local directory once they are committed, so that
+// the last files that were accepted are available if the other Loader fails.
+// Files that are loaded but never committed, such as files that fail to
+// parse, do not replace the saved copies.
+type Cache struct {
+	loader Loader
+	dir    string
+
+	mutex   sync.Mutex
+	pending map[string][]byte
+}
+
+// NewCache creates a Cache loader that loads files from the loader and saves
+// them in the directory, which is created if it does not exist.
+func NewCache(loader Loader, dir string) *Cache {
+	return &Cache{
+		loader: loader,
+		dir:    dir,
+	}
+}
+
+// LoadFile implements the LoadFile function specified in Loader interface, as defined in loader.go
+func (cache *Cache) LoadFile(fileName string) (io.ReadCloser, error) {
+	return cache.LoadFileContext(context.Background(), fileName)
+}
+
+// LoadFileContext implements the ContextLoader interface.
+func (cache *Cache) LoadFileContext(ctx context.Context, fileName string) (io.ReadCloser, error) {
+	files, err := cache.LoadBundleContext(ctx, []string{fileName})
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(files[fileName])), nil
+}
+
+// LoadBundle implements the BundleLoader interface. The files are only saved
+// if all of them are loaded successfully and then committed, so that the
+// cache does not mix files from different loads.
+func (cache *Cache) LoadBundle(fileNames []string) (map[string][]byte, error) {
+	return cache.LoadBundleContext(context.Background(), fileNames)
+}
+
+// LoadBundleContext implements the BundleContextLoader interface.
+func (cache *Cache) LoadBundleContext(ctx context.Context, fileNames []string) (map[string][]byte, error) {
+	files, err := LoadBundleContext(ctx, cache.loader, fileNames)
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if err != nil {
+		cache.pending = nil
+		return nil, err
+	}
+	cache.pending = files
+	return files, nil
+}
+
+// Commit implements the Committer interface. The files are saved if they are
+// the files returned by the last load, and are ignored otherwise, since they
+// were loaded from another source.
+func (cache *Cache) Commit(files map[string][]byte) error {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if cache.pending == nil || !sameFiles(files, cache.pending) {
+		return nil
+	}
+	if err := cache.save(files); err != nil {
+		return err
+	}
+	cache.pending = nil
+	return nil
+}
+
+// sameFiles returns true if both maps have the same file names and contents.
+func sameFiles(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for fileName, contents := range a {
+		other, ok := b[fileName]
+		if !ok || !bytes.Equal(contents, other) {
+			return false
+		}
+	}
+	return true
+}
+
+// Stored returns a Loader that loads the files saved in the cache directory.
+func (cache *Cache) Stored() Loader {
+	return NewFS(os.DirFS(cache.dir))
+}
+
+// save writes the files to the cache directory. Each file is written to a
+// temporary file first and then renamed, so that a failed write does not
+// leave a partial file in the cache.
+func (cache *Cache) save(files map[string][]byte) error {
+	if err := os.MkdirAll(cache.dir, 0755); err != nil {
+		return fmt.Errorf("could not create cache directory: %s", err)
+	}
+	tempFileNames := make(map[string]string, len(files))
+	defer func() {
+		for _, tempFileName := range tempFileNames {
+			os.Remove(tempFileName)
+		}
+	}()
+	for fileName, contents := range files {
+		if filepath.Base(fileName) != fileName {
+			return fmt.Errorf("could not cache %s: file name is not a base name", fileName)
+		}
+		tempFile, err := ioutil.TempFile(cache.dir, "."+fileName+".")
+		if err != nil {
+			return fmt.Errorf("could not cache %s: %s", fileName, err)
+		}
+		tempFileNames[fileName] = tempFile.Name()
+		_, err = tempFile.Write(contents)
+		if closeErr := tempFile.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("could not cache %s: %s", fileName, err)
+		}
+	}
+	for fileName, tempFileName := range tempFileNames {
+		if err := os.Rename(tempFileName, filepath.Join(cache.dir, fileName)); err != nil {
+			return fmt.Errorf("could not cache %s: %s", fileName, err)
+		}
+		delete(tempFileNames, fileName)
+	}
+	return nil
+}
+
+// A Source is a named Loader in a Fallback chain.
+type Source struct {
+	Name   string
+	Loader Loader
+}
+
+// Fallback implements interfaces Loader, BundleLoader, SourceLoader, their
+// context variants, and Committer by loading files from the first of a list of
+// sources that succeeds.
+type Fallback struct {
+	sources []Source
+}
+
+// NewFallback creates a Fallback loader that tries the sources in order.
+func NewFallback(sources ...Source) *Fallback {
+	return &Fallback{
+		sources: sources,
+	}
+}
+
+// LoadFile implements the LoadFile function specified in Loader interface, as defined in loader.go
+func (fallback *Fallback) LoadFile(fileName string) (io.ReadCloser, error) {
+	return fallback.LoadFileContext(context.Background(), fileName)
+}
+
+// LoadFileContext implements the ContextLoader interface.
+func (fallback *Fallback) LoadFileContext(ctx context.Context, fileName string) (io.ReadCloser, error) {
+	files, err := fallback.LoadBundleContext(ctx, []string{fileName})
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(files[fileName])), nil
+}
+
+// LoadBundle implements the BundleLoader interface.
+func (fallback *Fallback) LoadBundle(fileNames []string) (map[string][]byte, error) {
+	return fallback.LoadBundleContext(context.Background(), fileNames)
+}
+
+// LoadBundleContext implements the BundleContextLoader interface.
+func (fallback *Fallback) LoadBundleContext(ctx context.Context, fileNames []string) (map[string][]byte, error) {
+	files, _, err := fallback.LoadBundleSourceContext(ctx, fileNames)
+	return files, err
+}
+
+// LoadBundleSource implements the SourceLoader interface. All of the files are
+// loaded from the same source. If every source fails, the returned error
+// lists the error from each source.
+func (fallback *Fallback) LoadBundleSource(fileNames []string) (map[string][]byte, string, error) {
+	return fallback.LoadBundleSourceContext(context.Background(), fileNames)
+}
+
+// LoadBundleSourceContext implements the SourceContextLoader interface. If the
+// context is done, the remaining sources are not tried.
+func (fallback *Fallback) LoadBundleSourceContext(ctx context.Context, fileNames []string) (map[string][]byte, string, error) {
+	var errs []string
+	for _, source := range fallback.sources {
+		files, err := LoadBundleContext(ctx, source.Loader, fileNames)
+		if err == nil {
+			return files, source.Name, nil
+		}
+		if ctx.Err() != nil {
+			return nil, "", err
+		}
+		errs = append(errs, fmt.Sprintf("%s: %s", source.Name, err))
+	}
+	if len(errs) == 0 {
+		return nil, "", errors.New("loader: no fallback sources")
+	}
+	return nil, "", fmt.Errorf("loader: all fallback sources failed: %s", strings.Join(errs, "; "))
+}
+
+// Commit implements the Committer interface by committing the files to each
+// source that implements Committer, and returns the first error.
+func (fallback *Fallback) Commit(files map[string][]byte) error {
+	var firstErr error
+	for _, source := range fallback.sources {
+		if err := Commit(source.Loader, files); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %s", source.Name, err)
+		}
+	}
+	return firstErr
+}
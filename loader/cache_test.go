@@ -0,0 +1,184 @@
+package loader_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/mitmengine"
+	"github.com/cloudflare/mitmengine/loader"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+var testFileNames = []string{"browser.txt", "mitm.txt", "badheader.txt"}
+
+func TestCacheLoadBundle(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	remote := testutil.MapLoader(testBundleFiles())
+	cache := loader.NewCache(remote, dir)
+
+	// the cache is empty until the first load is committed
+	_, err := cache.Stored().LoadFile("browser.txt")
+	testutil.Assert(t, err != nil, "expected error for empty cache")
+
+	files, err := cache.LoadBundle(testFileNames)
+	testutil.Ok(t, err)
+	testutil.Equals(t, testBundleFiles(), files)
+	_, err = cache.Stored().LoadFile("browser.txt")
+	testutil.Assert(t, err != nil, "expected error for uncommitted files")
+
+	// files that were not returned by the last load are not saved
+	testutil.Ok(t, cache.Commit(map[string][]byte{"browser.txt": []byte("other")}))
+	_, err = cache.Stored().LoadFile("browser.txt")
+	testutil.Assert(t, err != nil, "expected error for files from another source")
+
+	testutil.Ok(t, cache.Commit(files))
+	for fileName, contents := range testBundleFiles() {
+		file, err := cache.Stored().LoadFile(fileName)
+		testutil.Ok(t, err)
+		stored, err := ioutil.ReadAll(file)
+		file.Close()
+		testutil.Ok(t, err)
+		testutil.Equals(t, contents, stored)
+	}
+
+	// a failed load leaves the previous files in the cache
+	remote["browser.txt"] = []byte("changed")
+	delete(remote, "mitm.txt")
+	_, err = cache.LoadBundle(testFileNames)
+	testutil.Assert(t, err != nil, "expected error for missing file")
+	testutil.Ok(t, cache.Commit(files))
+	stored, err := ioutil.ReadFile(filepath.Join(dir, "browser.txt"))
+	testutil.Ok(t, err)
+	testutil.Equals(t, testBundleFiles()["browser.txt"], stored)
+
+	// no temporary files are left behind
+	entries, err := ioutil.ReadDir(dir)
+	testutil.Ok(t, err)
+	testutil.Equals(t, len(testFileNames), len(entries))
+
+	// file names outside of the cache directory are rejected
+	remote["../browser.txt"] = []byte("outside")
+	files, err = cache.LoadBundle([]string{"../browser.txt"})
+	testutil.Ok(t, err)
+	testutil.Assert(t, cache.Commit(files) != nil, "expected error for file outside cache")
+	_, err = os.Stat(filepath.Join(dir, "..", "browser.txt"))
+	testutil.Assert(t, os.IsNotExist(err), "file written outside cache")
+}
+
+func TestFallbackLoadBundleSource(t *testing.T) {
+	broken := testutil.MapLoader{}
+	working := testutil.MapLoader(testBundleFiles())
+	var tests = []struct {
+		sources []loader.Source
+		source  string
+		err     bool
+	}{
+		{[]loader.Source{{"remote", working}, {"local", broken}}, "remote", false},
+		{[]loader.Source{{"remote", broken}, {"local", working}}, "local", false},
+		{[]loader.Source{{"remote", broken}, {"local", broken}}, "", true},
+		{nil, "", true},
+	}
+	for _, test := range tests {
+		files, source, err := loader.NewFallback(test.sources...).LoadBundleSource(testFileNames)
+		if test.err {
+			testutil.Assert(t, err != nil, "expected error for sources %v", test.sources)
+			continue
+		}
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.source, source)
+		testutil.Equals(t, testBundleFiles(), files)
+	}
+
+	// each source is loaded as a whole, so files are not mixed across sources
+	partial := testutil.MapLoader(testBundleFiles())
+	delete(partial, "mitm.txt")
+	files, source, err := loader.NewFallback(loader.Source{"remote", partial}, loader.Source{"local", working}).LoadBundleSource(testFileNames)
+	testutil.Ok(t, err)
+	testutil.Equals(t, "local", source)
+	testutil.Equals(t, testBundleFiles(), files)
+
+	// the error lists the error from each source
+	_, _, err = loader.NewFallback(loader.Source{"remote", broken}, loader.Source{"local", broken}).LoadBundleSource(testFileNames)
+	testutil.Assert(t, strings.Contains(err.Error(), "remote: ") && strings.Contains(err.Error(), "local: "), "missing source errors: %v", err)
+}
+
+func TestProcessorConfigFallback(t *testing.T) {
+	remote := testutil.MapLoader(testBundleFiles())
+	cache := loader.NewCache(remote, t.TempDir())
+	config := mitmengine.Config{
+		BrowserFileName:   "browser.txt",
+		MitmFileName:      "mitm.txt",
+		BadHeaderFileName: "badheader.txt",
+		Loader: loader.NewFallback(
+			loader.Source{Name: "remote", Loader: cache},
+			loader.Source{Name: "cache", Loader: cache.Stored()},
+			loader.Source{Name: "embedded", Loader: mitmengine.NewEmbeddedLoader()},
+		),
+	}
+
+	// the cache is empty, so the embedded files are used if the remote fails
+	delete(remote, "mitm.txt")
+	a, err := mitmengine.NewProcessor(&config)
+	testutil.Ok(t, err)
+	testutil.Equals(t, "embedded", a.Snapshot().Source)
+	testutil.Assert(t, a.Snapshot().BrowserDatabase.Len() > 1, "embedded files not loaded")
+
+	remote["mitm.txt"] = testBundleFiles()["mitm.txt"]
+	a, err = mitmengine.NewProcessor(&config)
+	testutil.Ok(t, err)
+	testutil.Equals(t, "remote", a.Snapshot().Source)
+	testutil.Equals(t, 1, a.Snapshot().BrowserDatabase.Len())
+
+	// the last files loaded from the remote are used if it fails again
+	delete(remote, "mitm.txt")
+	a, err = mitmengine.NewProcessor(&config)
+	testutil.Ok(t, err)
+	testutil.Equals(t, "cache", a.Snapshot().Source)
+	testutil.Equals(t, 1, a.Snapshot().BrowserDatabase.Len())
+
+	// a refresh reports the change of source even though the files are the same
+	remote["mitm.txt"] = testBundleFiles()["mitm.txt"]
+	changed, err := a.Refresh(&config)
+	testutil.Ok(t, err)
+	testutil.Assert(t, changed, "expected refresh")
+	testutil.Equals(t, "remote", a.Snapshot().Source)
+}
+
+func TestProcessorConfigCacheRejectsBadFiles(t *testing.T) {
+	dir := t.TempDir()
+	remote := testutil.MapLoader(testBundleFiles())
+	cache := loader.NewCache(remote, dir)
+	config := mitmengine.Config{
+		BrowserFileName:   "browser.txt",
+		MitmFileName:      "mitm.txt",
+		BadHeaderFileName: "badheader.txt",
+		Loader: loader.NewFallback(
+			loader.Source{Name: "remote", Loader: cache},
+			loader.Source{Name: "cache", Loader: cache.Stored()},
+		),
+	}
+	a, err := mitmengine.NewProcessor(&config)
+	testutil.Ok(t, err)
+	testutil.Equals(t, "remote", a.Snapshot().Source)
+
+	// files that the remote returns but that do not parse are not cached
+	remote["browser.txt"] = []byte("1:72:2:3:10.14:1:|garbage")
+	_, err = a.Refresh(&config)
+	testutil.Assert(t, err != nil, "expected error for bad browser file")
+	for fileName, contents := range testBundleFiles() {
+		stored, err := ioutil.ReadFile(filepath.Join(dir, fileName))
+		testutil.Ok(t, err)
+		testutil.Equals(t, contents, stored)
+	}
+
+	// so the cache still falls back to the last good files
+	delete(remote, "mitm.txt")
+	changed, err := a.Refresh(&config)
+	testutil.Ok(t, err)
+	testutil.Assert(t, changed, "expected refresh")
+	testutil.Equals(t, "cache", a.Snapshot().Source)
+	testutil.Equals(t, 1, a.Snapshot().BrowserDatabase.Len())
+}
@@ -0,0 +1,179 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// ContextLoader is implemented by loaders that stop loading a file when a
+// context is cancelled or its deadline passes. The method is named
+// LoadFileContext so that a ContextLoader can also implement Loader.
+type ContextLoader interface {
+	Loader
+	LoadFileContext(ctx context.Context, fileName string) (io.ReadCloser, error)
+}
+
+// ConditionalContextLoader is a ConditionalLoader that stops loading a file
+// when a context is cancelled or its deadline passes.
+type ConditionalContextLoader interface {
+	ConditionalLoader
+	LoadFileIfChangedContext(ctx context.Context, fileName string, version string) (io.ReadCloser, string, error)
+}
+
+// BundleContextLoader is a BundleLoader that stops loading files when a
+// context is cancelled or its deadline passes.
+type BundleContextLoader interface {
+	BundleLoader
+	LoadBundleContext(ctx context.Context, fileNames []string) (map[string][]byte, error)
+}
+
+// SourceContextLoader is a SourceLoader that stops loading files when a
+// context is cancelled or its deadline passes.
+type SourceContextLoader interface {
+	SourceLoader
+	LoadBundleSourceContext(ctx context.Context, fileNames []string) (map[string][]byte, string, error)
+}
+
+// LoadFileContext loads a file from any loader with a context. If the loader
+// does not implement ContextLoader, the file is loaded and read in a new
+// goroutine, which is abandoned if the context is done first.
+func LoadFileContext(ctx context.Context, loader Loader, fileName string) (io.ReadCloser, error) {
+	if contextLoader, ok := loader.(ContextLoader); ok {
+		return contextLoader.LoadFileContext(ctx, fileName)
+	}
+	var contents []byte
+	err := runContext(ctx, func() error {
+		file, err := loader.LoadFile(fileName)
+		if err != nil {
+			return err
+		}
+		contents, err = readAll(file, fileName)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(contents)), nil
+}
+
+// LoadFileIfChangedContext loads a file from any loader with a context, as
+// described for ConditionalLoader. If the loader does not implement
+// ConditionalLoader, the file is always loaded and the returned version is
+// empty. If the loader does not implement ConditionalContextLoader or
+// ContextLoader, the file is loaded as described for LoadFileContext.
+func LoadFileIfChangedContext(ctx context.Context, loader Loader, fileName string, version string) (io.ReadCloser, string, error) {
+	switch conditionalLoader := loader.(type) {
+	case ConditionalContextLoader:
+		return conditionalLoader.LoadFileIfChangedContext(ctx, fileName, version)
+	case ConditionalLoader:
+		var contents []byte
+		var newVersion string
+		err := runContext(ctx, func() error {
+			file, fileVersion, err := conditionalLoader.LoadFileIfChanged(fileName, version)
+			newVersion = fileVersion
+			if err != nil {
+				return err
+			}
+			contents, err = readAll(file, fileName)
+			return err
+		})
+		if err == ErrNotModified {
+			return nil, newVersion, err
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return ioutil.NopCloser(bytes.NewReader(contents)), newVersion, nil
+	default:
+		file, err := LoadFileContext(ctx, loader, fileName)
+		return file, "", err
+	}
+}
+
+// LoadBundleContext loads the files from any loader with a context, all at
+// once if it is a BundleLoader and one at a time otherwise. If the loader is
+// a BundleLoader that does not implement BundleContextLoader, the files are
+// loaded in a new goroutine, which is abandoned if the context is done first.
+func LoadBundleContext(ctx context.Context, loader Loader, fileNames []string) (map[string][]byte, error) {
+	files, _, err := LoadBundleSourceContext(ctx, loader, fileNames)
+	return files, err
+}
+
+// LoadBundleSourceContext loads the files from any loader with a context as
+// described for LoadBundleContext, and returns the name of the source that
+// they were loaded from if the loader is a SourceLoader.
+func LoadBundleSourceContext(ctx context.Context, loader Loader, fileNames []string) (map[string][]byte, string, error) {
+	var files map[string][]byte
+	var source string
+	var err error
+	switch bundleLoader := loader.(type) {
+	case SourceContextLoader:
+		return bundleLoader.LoadBundleSourceContext(ctx, fileNames)
+	case SourceLoader:
+		err = runContext(ctx, func() (err error) {
+			files, source, err = bundleLoader.LoadBundleSource(fileNames)
+			return err
+		})
+	case BundleContextLoader:
+		files, err = bundleLoader.LoadBundleContext(ctx, fileNames)
+	case BundleLoader:
+		err = runContext(ctx, func() (err error) {
+			files, err = bundleLoader.LoadBundle(fileNames)
+			return err
+		})
+	default:
+		files = make(map[string][]byte, len(fileNames))
+		for _, fileName := range fileNames {
+			contents, err := readFile(ctx, loader, fileName)
+			if err != nil {
+				return nil, "", err
+			}
+			files[fileName] = contents
+		}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return files, source, nil
+}
+
+// readFile returns the contents of a file from a loader.
+func readFile(ctx context.Context, loader Loader, fileName string) ([]byte, error) {
+	file, err := LoadFileContext(ctx, loader, fileName)
+	if err != nil {
+		return nil, err
+	}
+	return readAll(file, fileName)
+}
+
+// readAll returns the contents of a file and closes it.
+func readAll(file io.ReadCloser, fileName string) ([]byte, error) {
+	defer file.Close()
+	contents, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", fileName, err)
+	}
+	return contents, nil
+}
+
+// runContext calls load in a new goroutine and returns its error, or the
+// context's error if the context is done first. Variables set by load must
+// only be used if the returned error came from load.
+func runContext(ctx context.Context, load func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- load()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,67 @@
+package loader_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/mitmengine/loader"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+func TestLoadFileContext(t *testing.T) {
+	files := testutil.MapLoader(testBundleFiles())
+	file, err := loader.LoadFileContext(context.Background(), files, "badheader.txt")
+	testutil.Ok(t, err)
+	contents, err := ioutil.ReadAll(file)
+	testutil.Ok(t, err)
+	testutil.Equals(t, files["badheader.txt"], contents)
+	file, version, err := loader.LoadFileIfChangedContext(context.Background(), files, "badheader.txt", "")
+	testutil.Ok(t, err)
+	file.Close()
+	testutil.Equals(t, "", version)
+
+	blocked := make(chan struct{})
+	defer close(blocked)
+	blockedLoader := testutil.NewBlockingLoader(nil, blocked)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = loader.LoadFileContext(ctx, blockedLoader, "badheader.txt")
+	testutil.Equals(t, context.DeadlineExceeded, err)
+	_, _, err = loader.LoadFileIfChangedContext(ctx, blockedLoader, "badheader.txt", "")
+	testutil.Equals(t, context.DeadlineExceeded, err)
+	_, err = loader.LoadBundleContext(ctx, loader.NewVerified(blockedLoader, nil), []string{"badheader.txt"})
+	testutil.Equals(t, context.DeadlineExceeded, err)
+}
+
+func TestHTTPLoadFileContext(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+	httpLoader, err := loader.NewHTTP(server.URL, testHTTPOptions)
+	testutil.Ok(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = httpLoader.LoadFileContext(ctx, "badheader.txt")
+	testutil.Assert(t, errors.Is(err, context.DeadlineExceeded), "expected deadline error, got %v", err)
+}
+
+func TestFallbackLoadBundleSourceContext(t *testing.T) {
+	blocked := make(chan struct{})
+	defer close(blocked)
+	fallback := loader.NewFallback(
+		loader.Source{Name: "blocked", Loader: testutil.NewBlockingLoader(nil, blocked)},
+		loader.Source{Name: "memory", Loader: testutil.MapLoader(testBundleFiles())},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err := fallback.LoadBundleSourceContext(ctx, []string{"badheader.txt"})
+	testutil.Equals(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,29 @@
+package loader
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// FS implements interface Loader by reading files from a file system, such as
+// an embed.FS or the result of os.DirFS.
+type FS struct {
+	fsys fs.FS
+}
+
+// NewFS creates an FS loader that reads files from the file system.
+func NewFS(fsys fs.FS) *FS {
+	return &FS{
+		fsys: fsys,
+	}
+}
+
+// LoadFile implements the LoadFile function specified in Loader interface, as defined in loader.go
+func (fsInstance *FS) LoadFile(fileName string) (io.ReadCloser, error) {
+	file, err := fsInstance.fsys.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %s", fileName, err)
+	}
+	return file, nil
+}
@@ -0,0 +1,159 @@
+package loader
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTP implements interfaces Loader, ConditionalLoader, and their context
+// variants by fetching files relative to a base URL over HTTP or HTTPS.
+type HTTP struct {
+	baseURL *url.URL
+	header  http.Header
+	client  *http.Client
+}
+
+// A StatusError is returned by an HTTP loader when the server responds to a
+// request for a file with an unexpected status code.
+type StatusError struct {
+	FileName   string
+	StatusCode int
+	Status     string
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("could not read %s: %s", err.FileName, err.Status)
+}
+
+// HTTPOptions contains the options for an HTTP loader. Header is added to
+// every request, and BearerToken, if set, is sent in an Authorization header.
+// TLSConfig and Timeout configure the default client, and are ignored if
+// Client is set.
+type HTTPOptions struct {
+	Header      http.Header
+	BearerToken string
+	TLSConfig   *tls.Config
+	Timeout     time.Duration
+	Client      *http.Client
+}
+
+// NewHTTP creates an HTTP loader that fetches files relative to the base URL.
+func NewHTTP(baseURL string, options HTTPOptions) (*HTTP, error) {
+	parsedURL, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url '%s': %s", baseURL, err)
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base url '%s': scheme must be http or https", baseURL)
+	}
+	// resolve file names relative to the base url, not its parent
+	if !strings.HasSuffix(parsedURL.Path, "/") {
+		parsedURL.Path += "/"
+	}
+	header := make(http.Header)
+	for key, values := range options.Header {
+		header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+	}
+	if options.BearerToken != "" {
+		header.Set("Authorization", "Bearer "+options.BearerToken)
+	}
+	client := options.Client
+	if client == nil {
+		client = &http.Client{
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				TLSClientConfig:     options.TLSConfig,
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+			Timeout: options.Timeout,
+		}
+	}
+	return &HTTP{
+		baseURL: parsedURL,
+		header:  header,
+		client:  client,
+	}, nil
+}
+
+// LoadFile implements the LoadFile function specified in Loader interface, as defined in loader.go
+func (httpInstance *HTTP) LoadFile(fileName string) (io.ReadCloser, error) {
+	return httpInstance.LoadFileContext(context.Background(), fileName)
+}
+
+// LoadFileContext implements the ContextLoader interface. The request is
+// cancelled when the context is done, including while the returned file is
+// being read.
+func (httpInstance *HTTP) LoadFileContext(ctx context.Context, fileName string) (io.ReadCloser, error) {
+	file, _, err := httpInstance.LoadFileIfChangedContext(ctx, fileName, "")
+	return file, err
+}
+
+// LoadFileIfChanged implements the ConditionalLoader interface. The version of
+// a file is its ETag, or its Last-Modified time if the server does not return
+// an ETag.
+func (httpInstance *HTTP) LoadFileIfChanged(fileName string, version string) (io.ReadCloser, string, error) {
+	return httpInstance.LoadFileIfChangedContext(context.Background(), fileName, version)
+}
+
+// LoadFileIfChangedContext implements the ConditionalContextLoader interface.
+func (httpInstance *HTTP) LoadFileIfChangedContext(ctx context.Context, fileName string, version string) (io.ReadCloser, string, error) {
+	fileURL, err := httpInstance.baseURL.Parse(fileName)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not read %s: %s", fileName, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL.String(), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not read %s: %s", fileName, err)
+	}
+	for key, values := range httpInstance.header {
+		req.Header[key] = values
+	}
+	for key, values := range conditionalHeader(version) {
+		req.Header[key] = values
+	}
+	resp, err := httpInstance.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not read %s: %w", fileName, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, responseVersion(resp.Header), nil
+	case http.StatusNotModified:
+		resp.Body.Close()
+		return nil, version, ErrNotModified
+	default:
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		return nil, "", &StatusError{FileName: fileName, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+}
+
+// conditionalHeader returns the headers for a conditional request for a file
+// with the version returned by responseVersion.
+func conditionalHeader(version string) http.Header {
+	header := make(http.Header)
+	switch {
+	case version == "":
+	case strings.HasPrefix(version, "\"") || strings.HasPrefix(version, "W/"):
+		header.Set("If-None-Match", version)
+	default:
+		header.Set("If-Modified-Since", version)
+	}
+	return header
+}
+
+// responseVersion returns the version of a file from its response headers:
+// the ETag, or the Last-Modified time if there is no ETag.
+func responseVersion(header http.Header) string {
+	if etag := header.Get("ETag"); etag != "" {
+		return etag
+	}
+	return header.Get("Last-Modified")
+}
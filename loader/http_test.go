@@ -0,0 +1,178 @@
+package loader_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/mitmengine"
+	"github.com/cloudflare/mitmengine/loader"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+// newFileServer returns a handler that serves the files under /fingerprints/
+// with an ETag of the quoted file contents, and that requires the bearer token
+// and custom header.
+func newFileServer(files map[string]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("X-Custom") != "custom" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		contents, ok := files[strings.TrimPrefix(r.URL.Path, "/fingerprints/")]
+		if !ok || !strings.HasPrefix(r.URL.Path, "/fingerprints/") {
+			http.NotFound(w, r)
+			return
+		}
+		etag := `"` + contents + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(contents))
+	})
+}
+
+var testHTTPOptions = loader.HTTPOptions{
+	Header:      http.Header{"x-custom": []string{"custom"}},
+	BearerToken: "secret",
+}
+
+func TestHTTPLoadFile(t *testing.T) {
+	server := httptest.NewServer(newFileServer(map[string]string{"badheader.txt": "x-bluecoat-via"}))
+	defer server.Close()
+	var tests = []struct {
+		baseURL  string
+		fileName string
+		out      string
+		err      bool
+	}{
+		{server.URL + "/fingerprints", "badheader.txt", "x-bluecoat-via", false},
+		{server.URL + "/fingerprints/", "badheader.txt", "x-bluecoat-via", false},
+		{server.URL + "/fingerprints", "missing.txt", "", true},
+		{server.URL, "badheader.txt", "", true},
+	}
+	for _, test := range tests {
+		httpLoader, err := loader.NewHTTP(test.baseURL, testHTTPOptions)
+		testutil.Ok(t, err)
+		file, err := httpLoader.LoadFile(test.fileName)
+		if test.err {
+			testutil.Assert(t, err != nil, "expected error for '%s' in '%s'", test.fileName, test.baseURL)
+			continue
+		}
+		testutil.Ok(t, err)
+		contents, err := ioutil.ReadAll(file)
+		file.Close()
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.out, string(contents))
+	}
+
+	// requests without credentials are rejected
+	httpLoader, err := loader.NewHTTP(server.URL+"/fingerprints", loader.HTTPOptions{})
+	testutil.Ok(t, err)
+	_, err = httpLoader.LoadFile("badheader.txt")
+	testutil.Assert(t, err != nil, "expected error without credentials")
+}
+
+func TestHTTPLoadFileIfChanged(t *testing.T) {
+	files := map[string]string{"badheader.txt": "x-bluecoat-via"}
+	server := httptest.NewServer(newFileServer(files))
+	defer server.Close()
+	httpLoader, err := loader.NewHTTP(server.URL+"/fingerprints", testHTTPOptions)
+	testutil.Ok(t, err)
+
+	file, version, err := httpLoader.LoadFileIfChanged("badheader.txt", "")
+	testutil.Ok(t, err)
+	file.Close()
+	testutil.Equals(t, `"x-bluecoat-via"`, version)
+
+	_, newVersion, err := httpLoader.LoadFileIfChanged("badheader.txt", version)
+	testutil.Equals(t, loader.ErrNotModified, err)
+	testutil.Equals(t, version, newVersion)
+
+	files["badheader.txt"] = "x-forwarded-for"
+	file, newVersion, err = httpLoader.LoadFileIfChanged("badheader.txt", version)
+	testutil.Ok(t, err)
+	contents, err := ioutil.ReadAll(file)
+	file.Close()
+	testutil.Ok(t, err)
+	testutil.Equals(t, "x-forwarded-for", string(contents))
+	testutil.Equals(t, `"x-forwarded-for"`, newVersion)
+}
+
+func TestHTTPTLS(t *testing.T) {
+	server := httptest.NewTLSServer(newFileServer(map[string]string{"badheader.txt": "x-bluecoat-via"}))
+	defer server.Close()
+
+	// the server certificate is not trusted by default
+	httpLoader, err := loader.NewHTTP(server.URL+"/fingerprints", testHTTPOptions)
+	testutil.Ok(t, err)
+	_, err = httpLoader.LoadFile("badheader.txt")
+	testutil.Assert(t, err != nil, "expected error for untrusted certificate")
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+	options := testHTTPOptions
+	options.TLSConfig = &tls.Config{RootCAs: rootCAs}
+	httpLoader, err = loader.NewHTTP(server.URL+"/fingerprints", options)
+	testutil.Ok(t, err)
+	file, err := httpLoader.LoadFile("badheader.txt")
+	testutil.Ok(t, err)
+	file.Close()
+}
+
+func TestHTTPTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+	options := testHTTPOptions
+	options.Timeout = 10 * time.Millisecond
+	httpLoader, err := loader.NewHTTP(server.URL, options)
+	testutil.Ok(t, err)
+	_, err = httpLoader.LoadFile("badheader.txt")
+	testutil.Assert(t, err != nil, "expected timeout error")
+}
+
+func TestNewHTTPErrors(t *testing.T) {
+	var tests = []string{
+		"",
+		"ftp://example.com/fingerprints",
+		"http://[::1",
+	}
+	for _, test := range tests {
+		_, err := loader.NewHTTP(test, loader.HTTPOptions{})
+		testutil.Assert(t, err != nil, "expected error for base url '%s'", test)
+	}
+}
+
+func TestProcessorConfigHTTP(t *testing.T) {
+	server := httptest.NewServer(http.StripPrefix("/fingerprints/", http.FileServer(http.Dir(filepath.Join("..", "reference_fingerprints", "mitmengine")))))
+	defer server.Close()
+	httpLoader, err := loader.NewHTTP(server.URL+"/fingerprints", loader.HTTPOptions{})
+	testutil.Ok(t, err)
+	config := mitmengine.Config{
+		BrowserFileName:   "browser.txt",
+		MitmFileName:      "mitm.txt",
+		BadHeaderFileName: "badheader.txt",
+		Loader:            httpLoader,
+	}
+	a, err := mitmengine.NewProcessor(&config)
+	testutil.Ok(t, err)
+	testutil.Assert(t, a.Snapshot().BrowserDatabase.Len() > 0, "no browser records loaded")
+	testutil.Assert(t, a.Snapshot().MitmDatabase.Len() > 0, "no mitm records loaded")
+
+	// the file server sends Last-Modified, so unchanged files are not reloaded
+	changed, err := a.Refresh(&config)
+	testutil.Ok(t, err)
+	testutil.Assert(t, !changed, "unexpected refresh")
+}
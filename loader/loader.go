@@ -1,6 +1,13 @@
 package loader
 
-import "io"
+import (
+	"errors"
+	"io"
+)
+
+// ErrNotModified is returned by a ConditionalLoader when a file has not
+// changed since the given version.
+var ErrNotModified = errors.New("loader: file not modified")
 
 // Loader is the interface for loading files from any datasource you would like to specify;
 // make sure that you implement this interface when developing support for reading fingerprint files from
@@ -8,3 +15,14 @@ import "io"
 type Loader interface {
 	LoadFile(fileName string) (io.ReadCloser, error)
 }
+
+// ConditionalLoader is implemented by loaders that can tell whether a file has
+// changed without downloading it again, for example with an HTTP ETag or
+// Last-Modified header. LoadFileIfChanged returns the file and its current
+// version, or ErrNotModified if the file still has the given version. An empty
+// version always loads the file. An empty returned version means the loader
+// does not know the file's version.
+type ConditionalLoader interface {
+	Loader
+	LoadFileIfChanged(fileName string, version string) (io.ReadCloser, string, error)
+}
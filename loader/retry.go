@@ -0,0 +1,145 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/goamz/goamz/s3"
+)
+
+const (
+	defaultRetryAttempts   int           = 3
+	defaultRetryMinBackoff time.Duration = 100 * time.Millisecond
+	defaultRetryMaxBackoff time.Duration = 10 * time.Second
+)
+
+// Retry implements interfaces ConditionalContextLoader and ContextLoader by
+// loading files from another Loader and retrying loads that fail with an error
+// for which IsRetryable returns true, with exponential backoff. Files are loaded one at a time, so a Retry loader
+// should wrap the loader that reads from the network, inside any Verified,
+// Cache, or Fallback loader.
+type Retry struct {
+	loader  Loader
+	options RetryOptions
+}
+
+// RetryOptions contains the options for a Retry loader. Attempts is the
+// maximum number of times each file is loaded, and defaults to 3. The delay
+// before the second attempt is MinBackoff, which defaults to 100ms, and the
+// delay doubles after each attempt up to MaxBackoff, which defaults to 10s. If
+// Timeout is positive, each attempt is cancelled if it takes longer.
+type RetryOptions struct {
+	Attempts   int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	Timeout    time.Duration
+}
+
+// NewRetry creates a Retry loader that loads files from the loader.
+func NewRetry(loader Loader, options RetryOptions) *Retry {
+	if options.Attempts <= 0 {
+		options.Attempts = defaultRetryAttempts
+	}
+	if options.MinBackoff <= 0 {
+		options.MinBackoff = defaultRetryMinBackoff
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = defaultRetryMaxBackoff
+	}
+	return &Retry{
+		loader:  loader,
+		options: options,
+	}
+}
+
+// LoadFile implements the LoadFile function specified in Loader interface, as defined in loader.go
+func (retry *Retry) LoadFile(fileName string) (io.ReadCloser, error) {
+	return retry.LoadFileContext(context.Background(), fileName)
+}
+
+// LoadFileContext implements the ContextLoader interface.
+func (retry *Retry) LoadFileContext(ctx context.Context, fileName string) (io.ReadCloser, error) {
+	file, _, err := retry.LoadFileIfChangedContext(ctx, fileName, "")
+	return file, err
+}
+
+// LoadFileIfChanged implements the ConditionalLoader interface. If the other
+// loader is not a ConditionalLoader, the file is always loaded and the
+// returned version is empty.
+func (retry *Retry) LoadFileIfChanged(fileName string, version string) (io.ReadCloser, string, error) {
+	return retry.LoadFileIfChangedContext(context.Background(), fileName, version)
+}
+
+// LoadFileIfChangedContext implements the ConditionalContextLoader interface.
+// Each attempt reads the whole file, so that errors while reading are also
+// retried. Errors that are not retryable, including ErrNotModified, are
+// returned immediately, and no more attempts are made once the context is
+// done.
+func (retry *Retry) LoadFileIfChangedContext(ctx context.Context, fileName string, version string) (io.ReadCloser, string, error) {
+	backoff := retry.options.MinBackoff
+	for attempt := 1; ; attempt++ {
+		contents, newVersion, err := retry.attempt(ctx, fileName, version)
+		switch {
+		case err == nil:
+			return ioutil.NopCloser(bytes.NewReader(contents)), newVersion, nil
+		case err == ErrNotModified:
+			return nil, newVersion, err
+		case ctx.Err() != nil || !IsRetryable(err):
+			return nil, "", err
+		case attempt >= retry.options.Attempts:
+			return nil, "", fmt.Errorf("%w (after %d attempts)", err, attempt)
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, "", fmt.Errorf("%w (last error: %s)", ctx.Err(), err)
+		}
+		if backoff *= 2; backoff > retry.options.MaxBackoff {
+			backoff = retry.options.MaxBackoff
+		}
+	}
+}
+
+// attempt loads and reads a file once, with the configured timeout.
+func (retry *Retry) attempt(ctx context.Context, fileName string, version string) ([]byte, string, error) {
+	if retry.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, retry.options.Timeout)
+		defer cancel()
+	}
+	file, newVersion, err := LoadFileIfChangedContext(ctx, retry.loader, fileName, version)
+	if err != nil {
+		return nil, newVersion, err
+	}
+	contents, err := readAll(file, fileName)
+	if err != nil {
+		return nil, "", err
+	}
+	return contents, newVersion, nil
+}
+
+// IsRetryable returns true if a load that failed with the error may succeed if
+// it is retried: network errors, timeouts, and HTTP or S3 responses with a 5xx
+// status code. Other errors, such as a missing file or bad credentials, fail
+// the same way every time.
+func IsRetryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	var s3Err *s3.Error
+	if errors.As(err, &s3Err) {
+		return s3Err.StatusCode >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,156 @@
+package loader_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/mitmengine"
+	"github.com/cloudflare/mitmengine/loader"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+// flakyLoader fails the given number of loads with err, or with a 503
+// status error if err is nil, before loading files from another loader, and
+// blocks instead of failing if block is set.
+type flakyLoader struct {
+	sync.Mutex
+	loader   loader.Loader
+	failures int
+	err      error
+	block    chan struct{}
+	loads    int
+}
+
+func (a *flakyLoader) LoadFile(fileName string) (io.ReadCloser, error) {
+	a.Lock()
+	a.loads++
+	fail := a.loads <= a.failures
+	a.Unlock()
+	if fail && a.block != nil {
+		<-a.block
+	}
+	if fail && a.err != nil {
+		return nil, a.err
+	}
+	if fail {
+		return nil, &loader.StatusError{FileName: fileName, StatusCode: http.StatusServiceUnavailable, Status: "503 temporary failure"}
+	}
+	return a.loader.LoadFile(fileName)
+}
+
+func TestRetryLoadFile(t *testing.T) {
+	var tests = []struct {
+		failures int
+		attempts int
+		loads    int
+		err      bool
+	}{
+		{0, 0, 1, false},
+		{2, 0, 3, false},
+		{3, 0, 3, true},
+		{4, 5, 5, false},
+		{1, 1, 1, true},
+	}
+	for _, test := range tests {
+		flaky := &flakyLoader{loader: testutil.MapLoader(testBundleFiles()), failures: test.failures}
+		retry := loader.NewRetry(flaky, loader.RetryOptions{Attempts: test.attempts, MinBackoff: time.Millisecond})
+		file, err := retry.LoadFile("badheader.txt")
+		testutil.Equals(t, test.loads, flaky.loads)
+		if test.err {
+			testutil.Assert(t, err != nil, "expected error after %d failures", test.failures)
+			continue
+		}
+		testutil.Ok(t, err)
+		contents, err := ioutil.ReadAll(file)
+		testutil.Ok(t, err)
+		testutil.Equals(t, "x-bluecoat-via\n", string(contents))
+	}
+}
+
+func TestRetryErrors(t *testing.T) {
+	var tests = []struct {
+		err   error
+		loads int
+	}{
+		{&loader.StatusError{FileName: "badheader.txt", StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error"}, 3},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, 3},
+		{context.DeadlineExceeded, 3},
+		{&loader.StatusError{FileName: "badheader.txt", StatusCode: http.StatusNotFound, Status: "404 Not Found"}, 1},
+		{&loader.StatusError{FileName: "badheader.txt", StatusCode: http.StatusForbidden, Status: "403 Forbidden"}, 1},
+		{errors.New("environment variable not set"), 1},
+	}
+	for _, test := range tests {
+		flaky := &flakyLoader{loader: testutil.MapLoader(testBundleFiles()), failures: 3, err: test.err}
+		retry := loader.NewRetry(flaky, loader.RetryOptions{MinBackoff: time.Millisecond})
+		_, err := retry.LoadFile("badheader.txt")
+		testutil.Assert(t, errors.Is(err, test.err), "expected %v, got %v", test.err, err)
+		testutil.Equals(t, test.loads, flaky.loads)
+	}
+
+	// the status code of an HTTP response decides whether it is retried
+	server := httptest.NewServer(newFileServer(map[string]string{}))
+	defer server.Close()
+	httpLoader, err := loader.NewHTTP(server.URL+"/fingerprints", testHTTPOptions)
+	testutil.Ok(t, err)
+	_, err = httpLoader.LoadFile("badheader.txt")
+	testutil.Assert(t, !loader.IsRetryable(err), "expected 404 not to be retryable: %v", err)
+}
+
+func TestRetryNotModified(t *testing.T) {
+	server := httptest.NewServer(newFileServer(map[string]string{"badheader.txt": "x-bluecoat-via"}))
+	defer server.Close()
+	httpLoader, err := loader.NewHTTP(server.URL+"/fingerprints", testHTTPOptions)
+	testutil.Ok(t, err)
+	retry := loader.NewRetry(httpLoader, loader.RetryOptions{MinBackoff: time.Hour})
+	file, version, err := retry.LoadFileIfChanged("badheader.txt", "")
+	testutil.Ok(t, err)
+	file.Close()
+	testutil.Assert(t, version != "", "expected version")
+	_, notModifiedVersion, err := retry.LoadFileIfChanged("badheader.txt", version)
+	testutil.Equals(t, loader.ErrNotModified, err)
+	testutil.Equals(t, version, notModifiedVersion)
+}
+
+func TestRetryTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	flaky := &flakyLoader{loader: testutil.MapLoader(testBundleFiles()), failures: 1, block: block}
+	retry := loader.NewRetry(flaky, loader.RetryOptions{MinBackoff: time.Millisecond, Timeout: 10 * time.Millisecond})
+	file, err := retry.LoadFile("badheader.txt")
+	testutil.Ok(t, err)
+	file.Close()
+	testutil.Equals(t, 2, flaky.loads)
+}
+
+func TestRetryContext(t *testing.T) {
+	flaky := &flakyLoader{loader: testutil.MapLoader(testBundleFiles()), failures: 1}
+	retry := loader.NewRetry(flaky, loader.RetryOptions{MinBackoff: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := retry.LoadFileContext(ctx, "badheader.txt")
+	testutil.Assert(t, errors.Is(err, context.DeadlineExceeded), "expected deadline error, got %v", err)
+	testutil.Assert(t, strings.Contains(err.Error(), "temporary failure"), "expected last error in %v", err)
+	testutil.Equals(t, 1, flaky.loads)
+}
+
+func TestProcessorConfigRetry(t *testing.T) {
+	flaky := &flakyLoader{loader: testutil.MapLoader(testBundleFiles()), failures: 2}
+	a, err := mitmengine.NewProcessor(&mitmengine.Config{
+		BrowserFileName:   "browser.txt",
+		MitmFileName:      "mitm.txt",
+		BadHeaderFileName: "badheader.txt",
+		Loader:            loader.NewRetry(flaky, loader.RetryOptions{MinBackoff: time.Millisecond}),
+	})
+	testutil.Ok(t, err)
+	testutil.Equals(t, 1, a.Snapshot().BrowserDatabase.Len())
+	testutil.Equals(t, 5, flaky.loads)
+}
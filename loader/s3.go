@@ -3,6 +3,8 @@ package loader
 import (
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -15,51 +17,121 @@ import (
 // mitmengine.Processor.
 type S3 struct {
 	bucket *s3.Bucket
+	prefix string
 }
 
-// NewS3Instance creates S3 struct using configs loaded from environment variables
-func NewS3Instance() (*S3, error) {
-	accessKey, ok := os.LookupEnv("AWS_ACCESS_KEY_ID")
-	if !ok {
-		return nil, fmt.Errorf("environment variable '%s' not set", "AWS_ACCESS_KEY_ID")
+// S3Options contains the options for an S3 loader.
+//
+// The bucket is addressed at Endpoint, which is the URL or host name of an S3
+// compatible service, or at the S3 endpoint of the AWS Region if Endpoint is
+// empty. The bucket name is added to the endpoint host name unless PathStyle
+// is set, in which case it is added to the request path instead, as required
+// by some Ceph and MinIO deployments. Prefix is prepended to every file name,
+// with a separating '/' if it does not end with one. If Client is not set, a
+// default client with the given Timeout is used.
+type S3Options struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Region       string
+	Endpoint     string
+	Bucket       string
+	Prefix       string
+	PathStyle    bool
+	Timeout      time.Duration
+	Client       *http.Client
+}
+
+// NewS3 creates an S3 loader from the options.
+func NewS3(options S3Options) (*S3, error) {
+	if options.Bucket == "" {
+		return nil, fmt.Errorf("no bucket name specified")
 	}
-	secretKey, ok := os.LookupEnv("AWS_SECRET_ACCESS_KEY")
-	if !ok {
-		return nil, fmt.Errorf("environment variable '%s' not set", "AWS_SECRET_ACCESS_KEY")
+	region := aws.Region{Name: options.Region}
+	endpoint := options.Endpoint
+	if endpoint == "" {
+		awsRegion, ok := aws.Regions[options.Region]
+		if !ok {
+			return nil, fmt.Errorf("unknown region '%s' and no endpoint specified", options.Region)
+		}
+		region = awsRegion
+		endpoint = awsRegion.S3Endpoint
 	}
-	endpoint, ok := os.LookupEnv("AWS_ENDPOINT")
-	if !ok {
-		return nil, fmt.Errorf("environment variable '%s' not set", "AWS_ENDPOINT")
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
 	}
-	bucketName, ok := os.LookupEnv("AWS_BUCKET_NAME")
-	if !ok {
-		return nil, fmt.Errorf("environment variable '%s' not set", "AWS_BUCKET_NAME")
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil || endpointURL.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint '%s'", options.Endpoint)
 	}
-
-	auth, err := aws.GetAuth(strings.TrimSpace(string(accessKey)), strings.TrimSpace(string(secretKey)), "", time.Time{})
-	if err != nil {
-		return nil, fmt.Errorf("aws.GetAuth(): '%v'", err)
+	endpointURL.Path = strings.TrimSuffix(endpointURL.Path, "/")
+	if options.PathStyle {
+		region.S3Endpoint = endpointURL.String()
+		region.S3BucketEndpoint = ""
+	} else {
+		region.S3BucketEndpoint = fmt.Sprintf("%s://${bucket}.%s%s", endpointURL.Scheme, endpointURL.Host, endpointURL.Path)
 	}
 
-	region := aws.Region{
-		S3BucketEndpoint: fmt.Sprintf("https://%s.%s", bucketName, endpoint),
+	auth := aws.NewAuth(options.AccessKey, options.SecretKey, options.SessionToken, time.Time{})
+	var clients []*http.Client
+	if options.Client != nil {
+		clients = append(clients, options.Client)
 	}
+	s3Client := s3.New(*auth, region, clients...)
+	s3Client.ConnectTimeout = options.Timeout
+	s3Client.ReadTimeout = options.Timeout
+	s3Client.WriteTimeout = options.Timeout
 
-	// use the bucket to pull fingerprint files
-	mitmBucket := s3.New(auth, region).Bucket(bucketName)
-	if mitmBucket == nil {
-		return nil, fmt.Errorf("no bucket '%s' found at bucket endpoint '%s'", bucketName, endpoint)
+	prefix := options.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
 	}
 	return &S3{
-		bucket: mitmBucket,
+		bucket: s3Client.Bucket(options.Bucket),
+		prefix: prefix,
 	}, nil
 }
 
+// NewS3Instance creates S3 struct using configs loaded from environment variables
+func NewS3Instance() (*S3, error) {
+	var options S3Options
+	for _, variable := range []struct {
+		name  string
+		value *string
+	}{
+		{"AWS_ACCESS_KEY_ID", &options.AccessKey},
+		{"AWS_SECRET_ACCESS_KEY", &options.SecretKey},
+		{"AWS_ENDPOINT", &options.Endpoint},
+		{"AWS_BUCKET_NAME", &options.Bucket},
+	} {
+		value, ok := os.LookupEnv(variable.name)
+		if !ok {
+			return nil, fmt.Errorf("environment variable '%s' not set", variable.name)
+		}
+		*variable.value = strings.TrimSpace(value)
+	}
+	return NewS3(options)
+}
+
 // LoadFile implements the LoadFile function specified in Loader interface, as defined in loader.go
 func (s3Instance *S3) LoadFile(fileName string) (io.ReadCloser, error) {
-	reader, err := s3Instance.bucket.GetReader(fileName)
+	reader, err := s3Instance.bucket.GetReader(s3Instance.prefix + fileName)
 	if err != nil {
-		return nil, fmt.Errorf("could not read %s: %s", fileName, err)
+		return nil, fmt.Errorf("could not read %s: %w", fileName, err)
 	}
 	return reader, nil
 }
+
+// LoadFileIfChanged implements the ConditionalLoader interface. The version of
+// a file is its ETag, or its Last-Modified time if the bucket does not return
+// an ETag.
+func (s3Instance *S3) LoadFileIfChanged(fileName string, version string) (io.ReadCloser, string, error) {
+	resp, err := s3Instance.bucket.GetResponseWithHeaders(s3Instance.prefix+fileName, conditionalHeader(version))
+	if err != nil {
+		if s3Err, ok := err.(*s3.Error); ok && s3Err.StatusCode == http.StatusNotModified {
+			return nil, version, ErrNotModified
+		}
+		return nil, "", fmt.Errorf("could not read %s: %w", fileName, err)
+	}
+	return resp.Body, responseVersion(resp.Header), nil
+}
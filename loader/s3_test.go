@@ -0,0 +1,199 @@
+package loader_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/mitmengine"
+	"github.com/cloudflare/mitmengine/loader"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+// fakeS3 serves objects from a map of bucket names to object keys to object
+// contents, addressing buckets by path or by host name, and requires requests
+// to be signed with the access key.
+type fakeS3 struct {
+	accessKey string
+	domain    string
+	buckets   map[string]map[string]string
+}
+
+func (a *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS "+a.accessKey+":") {
+		writeS3Error(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+	bucket, key := "", strings.TrimPrefix(r.URL.Path, "/")
+	if host := strings.Split(r.Host, ":")[0]; strings.HasSuffix(host, "."+a.domain) {
+		bucket = strings.TrimSuffix(host, "."+a.domain)
+	} else {
+		split := strings.SplitN(key, "/", 2)
+		if len(split) != 2 {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		bucket, key = split[0], split[1]
+	}
+	contents, ok := a.buckets[bucket][key]
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	etag := `"` + contents + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Write([]byte(contents))
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+// newFakeS3Server returns a fake S3 server, and a client that connects to the
+// server for any host name.
+func newFakeS3Server(a *fakeS3) (*httptest.Server, *http.Client) {
+	server := httptest.NewServer(a)
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}}
+	return server, client
+}
+
+func TestS3LoadFile(t *testing.T) {
+	fake := &fakeS3{
+		accessKey: "AKID",
+		domain:    "s3.test",
+		buckets: map[string]map[string]string{
+			"bucket1": {"fingerprints/badheader.txt": "x-bluecoat-via", "badheader.txt": "x-forwarded-for"},
+			"bucket2": {"badheader.txt": "x-sophos-meta"},
+		},
+	}
+	server, client := newFakeS3Server(fake)
+	defer server.Close()
+	var tests = []struct {
+		options loader.S3Options
+		out     string
+		err     bool
+	}{
+		{loader.S3Options{Bucket: "bucket1", Prefix: "fingerprints", PathStyle: true, Endpoint: server.URL}, "x-bluecoat-via", false},
+		{loader.S3Options{Bucket: "bucket1", Prefix: "fingerprints/", PathStyle: true, Endpoint: server.URL}, "x-bluecoat-via", false},
+		{loader.S3Options{Bucket: "bucket1", PathStyle: true, Endpoint: server.URL}, "x-forwarded-for", false},
+		{loader.S3Options{Bucket: "bucket2", PathStyle: true, Endpoint: server.URL}, "x-sophos-meta", false},
+		{loader.S3Options{Bucket: "bucket1", Prefix: "fingerprints", Endpoint: "http://s3.test"}, "x-bluecoat-via", false},
+		{loader.S3Options{Bucket: "bucket2", Endpoint: "http://s3.test/"}, "x-sophos-meta", false},
+		{loader.S3Options{Bucket: "bucket2", Prefix: "fingerprints", PathStyle: true, Endpoint: server.URL}, "", true},
+		{loader.S3Options{Bucket: "bucket2", PathStyle: true, Endpoint: server.URL, AccessKey: "other"}, "", true},
+	}
+	for _, test := range tests {
+		options := test.options
+		if options.AccessKey == "" {
+			options.AccessKey = "AKID"
+		}
+		options.SecretKey = "secret"
+		options.Client = client
+		s3Loader, err := loader.NewS3(options)
+		testutil.Ok(t, err)
+		file, err := s3Loader.LoadFile("badheader.txt")
+		if test.err {
+			testutil.Assert(t, err != nil, "expected error for options %+v", test.options)
+			continue
+		}
+		testutil.Ok(t, err)
+		contents, err := ioutil.ReadAll(file)
+		file.Close()
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.out, string(contents))
+	}
+}
+
+func TestS3LoadFileIfChanged(t *testing.T) {
+	fake := &fakeS3{
+		accessKey: "AKID",
+		buckets:   map[string]map[string]string{"bucket": {"fingerprints/badheader.txt": "x-bluecoat-via"}},
+	}
+	server, client := newFakeS3Server(fake)
+	defer server.Close()
+	s3Loader, err := loader.NewS3(loader.S3Options{
+		AccessKey: "AKID",
+		SecretKey: "secret",
+		Endpoint:  server.URL,
+		Bucket:    "bucket",
+		Prefix:    "fingerprints",
+		PathStyle: true,
+		Client:    client,
+	})
+	testutil.Ok(t, err)
+
+	file, version, err := s3Loader.LoadFileIfChanged("badheader.txt", "")
+	testutil.Ok(t, err)
+	file.Close()
+	testutil.Equals(t, `"x-bluecoat-via"`, version)
+	_, _, err = s3Loader.LoadFileIfChanged("badheader.txt", version)
+	testutil.Equals(t, loader.ErrNotModified, err)
+
+	fake.buckets["bucket"]["fingerprints/badheader.txt"] = "x-forwarded-for"
+	file, version, err = s3Loader.LoadFileIfChanged("badheader.txt", version)
+	testutil.Ok(t, err)
+	file.Close()
+	testutil.Equals(t, `"x-forwarded-for"`, version)
+}
+
+func TestNewS3Errors(t *testing.T) {
+	var tests = []loader.S3Options{
+		{},
+		{Bucket: "bucket"},
+		{Bucket: "bucket", Region: "nowhere"},
+		{Bucket: "bucket", Endpoint: "http://"},
+		{Bucket: "bucket", Endpoint: "http://[::1"},
+	}
+	for _, test := range tests {
+		_, err := loader.NewS3(test)
+		testutil.Assert(t, err != nil, "expected error for options %+v", test)
+	}
+	_, err := loader.NewS3(loader.S3Options{Bucket: "bucket", Region: "us-west-2"})
+	testutil.Ok(t, err)
+}
+
+// Check that processors can load from different buckets in the same process
+func TestProcessorConfigS3Options(t *testing.T) {
+	files := testBundleFiles()
+	fake := &fakeS3{accessKey: "AKID", buckets: map[string]map[string]string{"bucket1": {}, "bucket2": {}}}
+	for fileName, contents := range files {
+		fake.buckets["bucket1"]["mitm/"+fileName] = string(contents)
+		fake.buckets["bucket2"][fileName] = string(contents)
+	}
+	fake.buckets["bucket2"]["browser.txt"] = ""
+	server, client := newFakeS3Server(fake)
+	defer server.Close()
+	var browserLens []int
+	for _, options := range []loader.S3Options{
+		{Bucket: "bucket1", Prefix: "mitm"},
+		{Bucket: "bucket2"},
+	} {
+		options.AccessKey, options.SecretKey = "AKID", "secret"
+		options.Endpoint, options.PathStyle, options.Client = server.URL, true, client
+		s3Loader, err := loader.NewS3(options)
+		testutil.Ok(t, err)
+		a, err := mitmengine.NewProcessor(&mitmengine.Config{
+			BrowserFileName:   "browser.txt",
+			MitmFileName:      "mitm.txt",
+			BadHeaderFileName: "badheader.txt",
+			Loader:            s3Loader,
+		})
+		testutil.Ok(t, err)
+		browserLens = append(browserLens, a.Snapshot().BrowserDatabase.Len())
+	}
+	testutil.Equals(t, []int{1, 0}, browserLens)
+}
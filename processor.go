@@ -4,15 +4,14 @@
 package mitmengine
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"errors"
-	"fmt"
 	"io"
-	"io/ioutil"
-	"log"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/cloudflare/mitmengine/db"
 	fp "github.com/cloudflare/mitmengine/fputil"
@@ -26,65 +25,171 @@ var (
 
 // A Processor generates heuristic-based monster-in-the-middle (MITM) detection
 // reports for a TLS client hello and corresponding HTTP user agent.
+//
+// The databases used by a Processor are held in a Snapshot that is replaced
+// atomically on Load, so a Processor can be reloaded while other goroutines
+// call Check. Copies of a Processor share the same state, except for copies of
+// a zero Processor made before its first Load or Store.
 type Processor struct {
-	FileNameMap     map[string]string
-	BrowserDatabase db.Database
-	MitmDatabase    db.Database
-	BadHeaderSet    fp.StringSet
+	FileNameMap map[string]string
+
+	// non-exported fields
+	state *processorState
+}
+
+// processorState is the state shared by copies of a Processor.
+type processorState struct {
+	snapshot  atomic.Value // *Snapshot
+	loadMutex sync.Mutex   // serializes loads and refreshes
+	stop      chan struct{}
+	stopOnce  sync.Once
 }
 
 // A Config contains information for initializing the processor such as the
 // file names to read records from, as well as Loader information in the case
 // the fingerprints are read from any datasource.
+//
+// If RefreshInterval is positive, NewProcessor starts refreshing the processor
+// in the background at that interval, until the processor is closed. OnReload
+// is called with the new snapshot whenever a refresh stores a new snapshot, and
+// OnReloadError is called whenever a refresh fails. If LoadTimeout is positive,
+// each load and refresh is cancelled if it takes longer.
+//
+// CipherCheck grades cipher suites and assesses the security of requests, and
+// defaults to fp.GlobalCipherCheck if nil. A CipherCheck created from a custom
+// fp.Registry recognizes cipher suites and named groups without a code change.
+// A new CipherCheck takes effect on the next Load or Refresh, even if no files
+// have changed.
 type Config struct {
 	BrowserFileName   string
 	MitmFileName      string
 	BadHeaderFileName string
 	Loader            loader.Loader
+	CipherCheck       *fp.CipherCheck
+
+	LoadTimeout     time.Duration
+	RefreshInterval time.Duration
+	OnReload        func(*Snapshot)
+	OnReloadError   func(error)
 }
 
 // NewProcessor returns a new Processor initialized from the config.
 func NewProcessor(config *Config) (Processor, error) {
-	var a Processor
-	err := a.Load(config)
-	return a, err
+	return NewProcessorContext(context.Background(), config)
+}
+
+// NewProcessorContext returns a new Processor initialized from the config. The
+// initial load is cancelled when the context is done, but background refreshes
+// are not.
+func NewProcessorContext(ctx context.Context, config *Config) (Processor, error) {
+	a := Processor{state: newProcessorState()}
+	if err := a.LoadContext(ctx, config); err != nil {
+		return a, err
+	}
+	if config.RefreshInterval > 0 {
+		go a.refreshLoop(*config)
+	}
+	return a, nil
+}
+
+// newProcessorState returns a new empty processor state.
+func newProcessorState() *processorState {
+	return &processorState{stop: make(chan struct{})}
 }
 
-// Load (or reload) the processor state from the provided configuration.
+// Load (or reload) the processor state from the provided configuration. The
+// new state is built in a new Snapshot and then swapped in, so concurrent
+// calls to Check see either the previous or the new databases. If the
+// processor already has a snapshot and any file cannot be loaded or parsed,
+// the previous snapshot is kept and the error is returned. On the first load,
+// files that cannot be loaded are logged and treated as empty.
 func (a *Processor) Load(config *Config) error {
-	browserFingerprints, err := LoadFile(config.BrowserFileName, config.Loader)
-	if err != nil {
-		log.Printf("WARNING: loading file \"%s\" produced error \"%s\"", config.BrowserFileName, err)
-		browserFingerprints = ioutil.NopCloser(bytes.NewReader(nil))
+	return a.LoadContext(context.Background(), config)
+}
+
+// LoadContext loads the processor state as described for Load, and stops
+// loading files when the context is done. If the context is done before the
+// files are loaded, the previous snapshot is kept and the error is returned,
+// even on the first load.
+func (a *Processor) LoadContext(ctx context.Context, config *Config) error {
+	if a.state == nil {
+		a.state = newProcessorState()
 	}
-	if a.BrowserDatabase, err = db.NewDatabase(browserFingerprints); err != nil {
+	ctx, cancel := config.loadContext(ctx)
+	defer cancel()
+	a.state.loadMutex.Lock()
+	defer a.state.loadMutex.Unlock()
+	snapshot, err := loadSnapshot(ctx, config, nil, a.Snapshot() == nil)
+	if err != nil {
 		return err
 	}
-	browserFingerprints.Close()
+	a.state.snapshot.Store(snapshot)
+	return nil
+}
 
-	mitmFingerprints, err := LoadFile(config.MitmFileName, config.Loader)
-	if err != nil {
-		log.Printf("WARNING: loading file \"%s\" produced error \"%s\"", config.MitmFileName, err)
-		mitmFingerprints = ioutil.NopCloser(bytes.NewReader(nil))
+// loadContext returns a context for a load that is cancelled after the
+// configured LoadTimeout, if any.
+func (config *Config) loadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if config.LoadTimeout > 0 {
+		return context.WithTimeout(ctx, config.LoadTimeout)
 	}
-	if a.MitmDatabase, err = db.NewDatabase(mitmFingerprints); err != nil {
-		return err
+	return context.WithCancel(ctx)
+}
+
+// Store atomically replaces the processor state with the snapshot.
+func (a *Processor) Store(snapshot *Snapshot) {
+	if a.state == nil {
+		a.state = newProcessorState()
 	}
-	mitmFingerprints.Close()
+	a.state.snapshot.Store(snapshot)
+}
 
-	badHeaders, err := LoadFile(config.BadHeaderFileName, config.Loader)
-	if err != nil {
-		log.Printf("WARNING: loading file \"%s\" produced error \"%s\"", config.BadHeaderFileName, err)
-		badHeaders = ioutil.NopCloser(bytes.NewReader(nil))
+// Snapshot returns the current processor state, or nil if the processor has
+// not been loaded.
+func (a Processor) Snapshot() *Snapshot {
+	if a.state == nil {
+		return nil
 	}
-	scanner := bufio.NewScanner(badHeaders)
-	var badHeaderList fp.StringList
-	for scanner.Scan() {
-		badHeaderList = append(badHeaderList, scanner.Text())
+	snapshot, _ := a.state.snapshot.Load().(*Snapshot)
+	return snapshot
+}
+
+// BrowserDatabase returns the browser database of the current snapshot, or an
+// empty database if the processor has not been loaded.
+//
+// Deprecated: The browser database is no longer a field of the Processor; use
+// Snapshot().BrowserDatabase, which is consistent with the other databases of
+// the snapshot.
+func (a Processor) BrowserDatabase() db.Database {
+	if snapshot := a.Snapshot(); snapshot != nil {
+		return snapshot.BrowserDatabase
+	}
+	return db.Database{}
+}
+
+// MitmDatabase returns the mitm database of the current snapshot, or an empty
+// database if the processor has not been loaded.
+//
+// Deprecated: The mitm database is no longer a field of the Processor; use
+// Snapshot().MitmDatabase, which is consistent with the other databases of the
+// snapshot.
+func (a Processor) MitmDatabase() db.Database {
+	if snapshot := a.Snapshot(); snapshot != nil {
+		return snapshot.MitmDatabase
 	}
-	a.BadHeaderSet = badHeaderList.Set()
-	badHeaders.Close()
+	return db.Database{}
+}
 
+// BadHeaderSet returns the bad header set of the current snapshot, or nil if
+// the processor has not been loaded.
+//
+// Deprecated: The bad header set is no longer a field of the Processor; use
+// Snapshot().BadHeaderSet, which is consistent with the databases of the
+// snapshot.
+func (a Processor) BadHeaderSet() fp.StringSet {
+	if snapshot := a.Snapshot(); snapshot != nil {
+		return snapshot.BadHeaderSet
+	}
 	return nil
 }
 
@@ -106,6 +211,17 @@ func LoadFile(fileName string, dbReader loader.Loader) (io.ReadCloser, error) {
 // hello fingerprints.
 func (a *Processor) Check(uaFingerprint fp.UAFingerprint, rawUa string, actualReqFin fp.RequestFingerprint) Report {
 
+	// Use the same snapshot for the whole check, even if the processor is
+	// reloaded concurrently.
+	snapshot := a.Snapshot()
+	if snapshot == nil {
+		snapshot = &Snapshot{}
+	}
+
+	// The fingerprints may be shared by concurrent checks, so clip the quirk
+	// list to make appends copy it.
+	uaFingerprint.Quirk = uaFingerprint.Quirk[:len(uaFingerprint.Quirk):len(uaFingerprint.Quirk)]
+
 	// Add user agent fingerprint quirks.
 	if strings.Contains(rawUa, "Dragon/") {
 		uaFingerprint.Quirk = append(uaFingerprint.Quirk, "dragon")
@@ -120,45 +236,25 @@ func (a *Processor) Check(uaFingerprint fp.UAFingerprint, rawUa string, actualRe
 		uaFingerprint.Quirk = append(uaFingerprint.Quirk, "playstation")
 	}
 
-	// Remove grease ciphers, extensions, and curves from request fingerprint and add as quirk instead.
-	hasGreaseCipher, newSize := removeGrease(actualReqFin.Cipher)
-	actualReqFin.Cipher = actualReqFin.Cipher[:newSize] // Remove grease ciphers
-
-	hasGreaseExtension, newSize := removeGrease(actualReqFin.Extension)
-	actualReqFin.Extension = actualReqFin.Extension[:newSize] // Remove grease extensions
-
-	hasGreaseCurve, newSize := removeGrease(actualReqFin.Curve)
-	actualReqFin.Curve = actualReqFin.Curve[:newSize] // Remove grease curves
-
-	if hasGreaseCipher || hasGreaseExtension || hasGreaseCurve {
-		actualReqFin.Quirk = append(actualReqFin.Quirk, "grease")
-	}
-
-	// Check for 'bad' headers that browsers never send and add as quirk.
-	hasBadHeader := false
-	for _, elem := range actualReqFin.Header {
-		if a.BadHeaderSet[elem] {
-			hasBadHeader = true
-		}
-	}
-	if hasBadHeader {
-		actualReqFin.Quirk = append(actualReqFin.Quirk, "badhdr")
-	}
+	// Remove grease values from the request fingerprint and add grease and
+	// 'bad' headers that browsers never send as quirks instead.
+	actualReqFin = actualReqFin.Normalize(snapshot.BadHeaderSet)
 
 	// Create mitm detection report
 	var r Report
+	r.JA4 = actualReqFin.JA4()
 
 	// Find the browser record matching the user agent fingerprint
-	browserRecordIds := a.BrowserDatabase.GetByUAFingerprint(uaFingerprint)
+	browserRecordIds := snapshot.BrowserDatabase.GetByUAFingerprint(uaFingerprint)
 	if len(browserRecordIds) == 0 {
-		return Report{Error: ErrorUnknownUserAgent}
+		return Report{JA4: r.JA4, Error: ErrorUnknownUserAgent}
 	}
 	var browserRecord db.Record
 	var maxSimilarity int
 	match := false
 
 	for _, id := range browserRecordIds {
-		tempRecord := a.BrowserDatabase.Records[id]
+		tempRecord := snapshot.BrowserDatabase.Records[id]
 		recordMatch, similarity := tempRecord.RequestSignature.Match(actualReqFin)
 		if recordMatch == fp.MatchPossible {
 			match = true
@@ -175,8 +271,17 @@ func (a *Processor) Check(uaFingerprint fp.UAFingerprint, rawUa string, actualRe
 
 	r.MatchedUASignature = browserRecord.UASignature.String()
 	r.BrowserSignature = browserReqSig.String()
-	r.BrowserGrade = browserReqSig.Grade()
-	r.ActualGrade = actualReqFin.Version.Grade().Merge(fp.GlobalCipherCheck.Grade(actualReqFin.Cipher))
+	cipherCheck := snapshot.cipherCheck()
+	r.BrowserGradeExplanation = cipherCheck.ExplainGrade(browserReqSig.Cipher.OrderedList)
+	r.BrowserGrade = r.BrowserGradeExplanation.Grade
+	r.Security = cipherCheck.Assess(actualReqFin)
+	r.WeakCiphers = len(r.Security.WeakCiphers) > 0
+	// The other security findings are not part of the actual grade, since the
+	// browser grade does not include them either, and are only reported in
+	// r.Security.
+	r.ActualGradeExplanation = actualReqFin.Version.ExplainGrade().Merge(cipherCheck.ExplainGrade(actualReqFin.Cipher))
+	r.ActualGrade = r.ActualGradeExplanation.Grade
+	r.VersionDowngrade = browserReqSig.IsVersionDowngrade(actualReqFin)
 
 	// No need to add to the report if we have match.
 	if match {
@@ -185,85 +290,22 @@ func (a *Processor) Check(uaFingerprint fp.UAFingerprint, rawUa string, actualRe
 	}
 
 	// Find the heuristics that flagged the connection as invalid
-	matchMap, _ := browserReqSig.MatchMap(actualReqFin)
-	var reason []string
-	var reasonDetails []string
-	switch {
-	case matchMap["version"] == fp.MatchImpossible:
-		r.BrowserSignatureMatch = fp.MatchImpossible
-		reason = append(reason, "impossible_version")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Version, actualReqFin.Version))
-	case matchMap["cipher"] == fp.MatchImpossible:
-		r.BrowserSignatureMatch = fp.MatchImpossible
-		reason = append(reason, "impossible_cipher")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Cipher, actualReqFin.Cipher.String()))
-	case matchMap["extension"] == fp.MatchImpossible:
-		r.BrowserSignatureMatch = fp.MatchImpossible
-		reason = append(reason, "impossible_extension")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Extension, actualReqFin.Extension.String()))
-	case matchMap["curve"] == fp.MatchImpossible:
-		r.BrowserSignatureMatch = fp.MatchImpossible
-		reason = append(reason, "impossible_curve")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Curve, actualReqFin.Curve.String()))
-	case matchMap["ecpointfmt"] == fp.MatchImpossible:
-		r.BrowserSignatureMatch = fp.MatchImpossible
-		reason = append(reason, "impossible_ecpointfmt")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.EcPointFmt, actualReqFin.EcPointFmt.String()))
-	case matchMap["header"] == fp.MatchImpossible:
-		r.BrowserSignatureMatch = fp.MatchImpossible
-		reason = append(reason, "impossible_header")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Header, actualReqFin.Header))
-	case matchMap["quirk"] == fp.MatchImpossible:
-		r.BrowserSignatureMatch = fp.MatchImpossible
-		reason = append(reason, "impossible_quirk")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Quirk, actualReqFin.Quirk))
-	// put 'unlikely' reasons after 'impossible' reasons
-	case matchMap["version"] == fp.MatchUnlikely:
-		r.BrowserSignatureMatch = fp.MatchUnlikely
-		reason = append(reason, "unlikely_version")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Version, actualReqFin.Version))
-	case matchMap["cipher"] == fp.MatchUnlikely:
-		r.BrowserSignatureMatch = fp.MatchUnlikely
-		reason = append(reason, "unlikely_cipher")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Cipher, actualReqFin.Cipher.String()))
-	case matchMap["extension"] == fp.MatchUnlikely:
-		r.BrowserSignatureMatch = fp.MatchUnlikely
-		reason = append(reason, "unlikely_extension")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Extension, actualReqFin.Extension.String()))
-	case matchMap["curve"] == fp.MatchUnlikely:
-		r.BrowserSignatureMatch = fp.MatchUnlikely
-		reason = append(reason, "unlikely_curve")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Curve, actualReqFin.Curve.String()))
-	case matchMap["ecpointfmt"] == fp.MatchUnlikely:
-		r.BrowserSignatureMatch = fp.MatchUnlikely
-		reason = append(reason, "unlikely_ecpointfmt")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.EcPointFmt, actualReqFin.EcPointFmt.String()))
-	case matchMap["header"] == fp.MatchUnlikely:
-		r.BrowserSignatureMatch = fp.MatchUnlikely
-		reason = append(reason, "unlikely_header")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Header, actualReqFin.Header))
-	case matchMap["quirk"] == fp.MatchUnlikely:
-		r.BrowserSignatureMatch = fp.MatchUnlikely
-		reason = append(reason, "unlikely_quirk")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Quirk, actualReqFin.Quirk))
-	default:
-		r.BrowserSignatureMatch = fp.MatchPossible
-	}
-	r.Reason = strings.Join(reason, ",")
-	r.ReasonDetails = strings.Join(reasonDetails, ",")
+	r.Findings = newFindings(browserReqSig, actualReqFin)
+	r.BrowserSignatureMatch, r.Reason, r.ReasonDetails = findingsReason(r.Findings)
 
 	// Check if MITM affects the connection security level
 	switch r.BrowserSignatureMatch {
 	case fp.MatchImpossible, fp.MatchUnlikely:
-		if browserReqSig.IsPfs() && fp.GlobalCipherCheck.IsFirstPfs(actualReqFin.Cipher) {
+		if cipherCheck.IsFirstPfs(browserReqSig.Cipher.OrderedList) && !cipherCheck.IsFirstPfs(actualReqFin.Cipher) {
 			r.LosesPfs = true
 		}
-		mitmRecordIds := a.MitmDatabase.GetByRequestFingerprint(actualReqFin)
+		mitmRecordIds := snapshot.MitmDatabase.GetByRequestFingerprint(actualReqFin)
 		if len(mitmRecordIds) == 0 {
 			break
 		}
-		mitmRecord := a.MitmDatabase.Records[mitmRecordIds[0]]
-		r.ActualGrade = r.ActualGrade.Merge(mitmRecord.MitmInfo.Grade)
+		mitmRecord := snapshot.MitmDatabase.Records[mitmRecordIds[0]]
+		r.ActualGradeExplanation.Add(fp.GradeFactor{Name: fp.GradeFactorMitm, Value: mitmRecord.MitmInfo.NameList.String(), Grade: mitmRecord.MitmInfo.Grade})
+		r.ActualGrade = r.ActualGradeExplanation.Grade
 		r.MatchedMitmName = mitmRecord.MitmInfo.NameList.String()
 		r.MatchedMitmType = mitmRecord.MitmInfo.Type
 		r.MatchedMitmSignature = mitmRecord.RequestSignature.String()
@@ -272,16 +314,3 @@ func (a *Processor) Check(uaFingerprint fp.UAFingerprint, rawUa string, actualRe
 	return r
 }
 
-func removeGrease(list fp.IntList) (bool, int) {
-	hasGrease := false
-	idx := 0
-	for _, elem := range list {
-		if (elem & 0x0f0f) == 0x0a0a {
-			hasGrease = true
-		} else {
-			list[idx] = elem
-			idx++
-		}
-	}
-	return hasGrease, idx
-}
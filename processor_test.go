@@ -179,6 +179,7 @@ func _TestProcessorCheckSequential(t *testing.T, config *mitmengine.Config) {
 		actual := a.Check(uaFingerprint, test.rawUa, fingerprint)
 		testutil.Equals(t, test.out.Error, actual.Error)
 		testutil.Equals(t, test.out.BrowserSignatureMatch, actual.BrowserSignatureMatch)
+		testutil.Equals(t, fingerprint.JA4(), actual.JA4)
 	}
 }
 
@@ -393,3 +394,446 @@ func BenchmarkProcessorCheckConcurrent(b *testing.B) {
 		_TestProcessorCheckConcurrent(t, &testConfigFile)
 	}
 }
+
+func BenchmarkProcessorCheckMitm(b *testing.B) {
+	testConfigFile := mitmengine.Config{
+		BrowserFileName:   filepath.Join("reference_fingerprints", "mitmengine", "browser.txt"),
+		MitmFileName:      filepath.Join("reference_fingerprints", "mitmengine", "mitm.txt"),
+		BadHeaderFileName: filepath.Join("reference_fingerprints", "mitmengine", "badheader.txt"),
+	}
+	a, err := mitmengine.NewProcessor(&testConfigFile)
+	if err != nil {
+		b.Fatal(err)
+	}
+	rawUa := "Mozilla/5.0 (Windows NT 6.1; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/70.0.3538.110 Safari/537.36"
+	var userAgent ua.UserAgent
+	ua.ParseUserAgent(rawUa, &userAgent)
+	uaFingerprint := fp.UAFingerprint{
+		BrowserName:    int(userAgent.Browser.Name),
+		BrowserVersion: fp.UAVersion(userAgent.Browser.Version),
+		OSPlatform:     int(userAgent.OS.Platform),
+		OSName:         int(userAgent.OS.Name),
+		OSVersion:      fp.UAVersion(userAgent.OS.Version),
+		DeviceType:     int(userAgent.DeviceType),
+	}
+	// a request from an interception proxy, which needs a mitm database lookup
+	requestFingerprint, err := fp.NewRequestFingerprint("303:c028,c027,c014,c013,9f,9e,9d,9c,3d,3c,35,2f,c02c,c02b,c024,c023,c00a,c009,6a,40,38,32,a,13:0,a,b,d,17,ff01:17,18:0::")
+	if err != nil {
+		b.Fatal(err)
+	}
+	if report := a.Check(uaFingerprint, rawUa, requestFingerprint); report.MatchedMitmName == "" {
+		b.Fatalf("no mitm match: %+v", report)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			a.Check(uaFingerprint, rawUa, requestFingerprint)
+		}
+	})
+}
+
+// Check that mismatches in each request field are reported with the expected reason
+func TestProcessorCheckReason(t *testing.T) {
+	browserRecord := "1:72:2:3:10.14:1:|303:c02b,c02f:0,a,b,d,10:1d,17:0:*:*:403,804,?401:h2,http/1.1|:0:0"
+	var tests = []struct {
+		fingerprint string
+		match       fp.Match
+		reason      string
+	}{
+		{"303:c02b,c02f:0,a,b,d,10:1d,17:0:::403,804,401:h2,http/1.1", fp.MatchPossible, ""},
+		{"303:c02b,c02f:0,a,b,d,10:1d,17:0:::403,804:h2,http/1.1", fp.MatchPossible, ""},
+		{"303:c02b,c02f:0,a,b,d,10:1d,17:0::", fp.MatchPossible, ""},
+		{"303:c02b,c02f:0,a,b,d,10:1d,17:0:::804,403:h2,http/1.1", fp.MatchImpossible, "impossible_sigalg"},
+		{"303:c02b,c02f:0,a,b,d,10:1d,17:0:::403,804,401,501:h2,http/1.1", fp.MatchImpossible, "impossible_sigalg"},
+		{"303:c02b:0,a,b,d,10:1d,17:0:::804,403:h2,http/1.1", fp.MatchImpossible, "impossible_cipher"},
+		{"303:c02b,c02f:0,a,b,d,10:1d,17:0:::403,804:http/1.1", fp.MatchImpossible, "impossible_alpn"},
+		{"303:c02b,c02f:0,a,b,d,10:1d,17:0:::403,804:h2", fp.MatchImpossible, "impossible_alpn"},
+	}
+	browserDatabase, err := db.NewDatabase(strings.NewReader(browserRecord))
+	testutil.Ok(t, err)
+	var a mitmengine.Processor
+	a.Store(&mitmengine.Snapshot{BrowserDatabase: browserDatabase})
+	uaFingerprint, err := fp.NewUAFingerprint("1:72.0.3626:2:3:10.14.3:1:")
+	testutil.Ok(t, err)
+	for _, test := range tests {
+		fingerprint, err := fp.NewRequestFingerprint(test.fingerprint)
+		testutil.Ok(t, err)
+		actual := a.Check(uaFingerprint, "", fingerprint)
+		testutil.Ok(t, actual.Error)
+		testutil.Equals(t, test.match, actual.BrowserSignatureMatch)
+		testutil.Equals(t, test.reason, actual.Reason)
+	}
+}
+
+// Check that every mismatched request field is reported as a finding
+func TestProcessorCheckFindings(t *testing.T) {
+	browserRecords := strings.Join([]string{
+		"1:72:2:3:10.14:1:|303:c02b,c02f:0,a,b,d,10:1d,17:0:*:*:403,804,?401:h2,http/1.1|:0:0",
+		"1:60:2:3:10.14:1:|303:~c02b,c02f,^5,!9c:*:*:*:*:*|:0:0",
+	}, "\n")
+	var tests = []struct {
+		ua          string
+		fingerprint string
+		reason      string
+		details     string
+		findings    []mitmengine.Finding
+	}{
+		{
+			"1:72.0.3626:2:3:10.14.3:1:", "303:c02f,c02b,9c:0,a,b,d:1d,17:0:::403,804:h2,http/1.1",
+			"impossible_cipher", "c02b,c02f vs c02f,c02b,9c",
+			[]mitmengine.Finding{
+				{Field: "cipher", Match: fp.MatchImpossible, Expected: "c02b,c02f", Actual: "c02f,c02b,9c", Extra: []string{"9c"}, OutOfOrder: []string{"c02f", "c02b"}},
+				{Field: "extension", Match: fp.MatchImpossible, Expected: "0,a,b,d,10", Actual: "0,a,b,d", Missing: []string{"10"}},
+			},
+		},
+		{
+			"1:72.0.3626:2:3:10.14.3:1:", "303:c02b,c02f:0,a,b,d,10:1d,17:0:::403,804:h2",
+			"impossible_alpn", "h2,http/1.1 vs h2",
+			[]mitmengine.Finding{
+				{Field: "alpn", Match: fp.MatchImpossible, Expected: "h2,http/1.1", Actual: "h2", Missing: []string{"http/1.1"}},
+			},
+		},
+		{
+			"1:60.0.3112:2:3:10.14.3:1:", "303:c02f,c02b,9c:0:1d:0::",
+			"unlikely_cipher", "~^5,!9c,c02b,c02f vs c02f,c02b,9c",
+			[]mitmengine.Finding{
+				{Field: "cipher", Match: fp.MatchUnlikely, Expected: "~^5,!9c,c02b,c02f", Actual: "c02f,c02b,9c", Unlikely: []string{"9c"}},
+			},
+		},
+		{
+			"1:60.0.3112:2:3:10.14.3:1:", "303:c02f,5:0:1d:0::",
+			"impossible_cipher", "~^5,!9c,c02b,c02f vs c02f,5",
+			[]mitmengine.Finding{
+				{Field: "cipher", Match: fp.MatchImpossible, Expected: "~^5,!9c,c02b,c02f", Actual: "c02f,5", Missing: []string{"c02b"}, Excluded: []string{"5"}},
+			},
+		},
+	}
+	browserDatabase, err := db.NewDatabase(strings.NewReader(browserRecords))
+	testutil.Ok(t, err)
+	var a mitmengine.Processor
+	a.Store(&mitmengine.Snapshot{BrowserDatabase: browserDatabase})
+	for _, test := range tests {
+		uaFingerprint, err := fp.NewUAFingerprint(test.ua)
+		testutil.Ok(t, err)
+		fingerprint, err := fp.NewRequestFingerprint(test.fingerprint)
+		testutil.Ok(t, err)
+		actual := a.Check(uaFingerprint, "", fingerprint)
+		testutil.Ok(t, actual.Error)
+		testutil.Equals(t, test.reason, actual.Reason)
+		testutil.Equals(t, test.details, actual.ReasonDetails)
+		testutil.Equals(t, test.findings, actual.Findings)
+	}
+}
+
+// Check that requests without TLS 1.3 are flagged as version downgrades if the
+// browser signature requires TLS 1.3 support
+func TestProcessorCheckVersionDowngrade(t *testing.T) {
+	browserRecords := strings.Join([]string{
+		"1:72:2:3:10.14:1:|303:*:*:*:*:*:*:*:*:~304,?303|:0:0",
+		"1:60:2:3:10.14:1:|303:*:*:*:*:*:*|:0:0",
+	}, "\n")
+	var tests = []struct {
+		ua          string
+		fingerprint string
+		downgrade   bool
+		reason      string
+	}{
+		{"1:72.0.3626:2:3:10.14.3:1:", "303:c02b:0,2b:1d:0:::::fafa,304,303", false, ""},
+		{"1:72.0.3626:2:3:10.14.3:1:", "303:c02b:0,2b:1d:0:::::303", true, "impossible_supportedversion"},
+		{"1:72.0.3626:2:3:10.14.3:1:", "303:c02b:0:1d:0::", true, "impossible_supportedversion"},
+		// the supported versions are not known, so this is not a downgrade
+		{"1:72.0.3626:2:3:10.14.3:1:", "303:c02b:0,2b:1d:0::", false, ""},
+		{"1:60.0.3112:2:3:10.14.3:1:", "303:c02b:0:1d:0::", false, ""},
+	}
+	browserDatabase, err := db.NewDatabase(strings.NewReader(browserRecords))
+	testutil.Ok(t, err)
+	var a mitmengine.Processor
+	a.Store(&mitmengine.Snapshot{BrowserDatabase: browserDatabase})
+	for _, test := range tests {
+		uaFingerprint, err := fp.NewUAFingerprint(test.ua)
+		testutil.Ok(t, err)
+		fingerprint, err := fp.NewRequestFingerprint(test.fingerprint)
+		testutil.Ok(t, err)
+		actual := a.Check(uaFingerprint, "", fingerprint)
+		testutil.Ok(t, actual.Error)
+		testutil.Equals(t, test.downgrade, actual.VersionDowngrade)
+		testutil.Equals(t, test.reason, actual.Reason)
+	}
+}
+
+// Check that weak ciphers and other security weaknesses of the request are
+// reported, and that only weak versions and ciphers are included in the actual
+// grade
+func TestProcessorCheckSecurity(t *testing.T) {
+	browserRecords := "1:72:2:3:10.14:1:|303:*:*:*:*:*:*|:0:0"
+	var tests = []struct {
+		fingerprint   string
+		weakCiphers   bool
+		findings      []string
+		grade         fp.Grade
+		securityGrade fp.Grade
+	}{
+		{"303:c02b:ff01:1d:0::", false, nil, fp.GradeA, fp.GradeEmpty},
+		{"303:c02b,5,4:ff01:1d:0::", true, []string{fp.FindingKnownAttackCipher}, fp.GradeC, fp.GradeC},
+		{"303:c02b,3:ff01,f:1d,1:0,1::", true, []string{fp.FindingBrokenCipher, fp.FindingWeakCurve, fp.FindingCompressedPointFmt, fp.FindingHeartbeat}, fp.GradeF, fp.GradeF},
+		{"303:c02b:ff01,f:1d:0::", false, []string{fp.FindingHeartbeat}, fp.GradeA, fp.GradeC},
+		{"301:c02b:0:1d:0::", false, []string{fp.FindingNoRenegotiationInfo}, fp.GradeB, fp.GradeC},
+	}
+	browserDatabase, err := db.NewDatabase(strings.NewReader(browserRecords))
+	testutil.Ok(t, err)
+	var a mitmengine.Processor
+	a.Store(&mitmengine.Snapshot{BrowserDatabase: browserDatabase})
+	uaFingerprint, err := fp.NewUAFingerprint("1:72.0.3626:2:3:10.14.3:1:")
+	testutil.Ok(t, err)
+	for _, test := range tests {
+		fingerprint, err := fp.NewRequestFingerprint(test.fingerprint)
+		testutil.Ok(t, err)
+		actual := a.Check(uaFingerprint, "", fingerprint)
+		testutil.Ok(t, actual.Error)
+		testutil.Equals(t, test.weakCiphers, actual.WeakCiphers)
+		var findings []string
+		for _, finding := range actual.Security.Findings {
+			findings = append(findings, finding.Name)
+		}
+		testutil.Equals(t, test.findings, findings)
+		testutil.Equals(t, test.grade, actual.ActualGrade)
+		testutil.Equals(t, test.securityGrade, actual.Security.Grade)
+	}
+}
+
+// Check that a MITM that replaces the browser's first cipher suite with one
+// without perfect forward secrecy is reported, including a downgrade from a
+// TLS 1.3 cipher suite to RSA key exchange
+func TestProcessorCheckLosesPfs(t *testing.T) {
+	var tests = []struct {
+		browserRecords string
+		fingerprint    string
+		losesPfs       bool
+	}{
+		{"1:72:2:3:10.14:1:|303:1301,1302,c02b:*:*:*:*:*|:0:0", "303:9c,2f:ff01:1d:0::", true},
+		{"1:72:2:3:10.14:1:|303:c02b,c02f:*:*:*:*:*|:0:0", "303:9c,2f:ff01:1d:0::", true},
+		{"1:72:2:3:10.14:1:|303:1301,1302,c02b:*:*:*:*:*|:0:0", "303:c02f,9c:ff01:1d:0::", false},
+		{"1:72:2:3:10.14:1:|303:9c,2f:*:*:*:*:*|:0:0", "303:2f,9c:ff01:1d:0::", false},
+	}
+	uaFingerprint, err := fp.NewUAFingerprint("1:72.0.3626:2:3:10.14.3:1:")
+	testutil.Ok(t, err)
+	for _, test := range tests {
+		browserDatabase, err := db.NewDatabase(strings.NewReader(test.browserRecords))
+		testutil.Ok(t, err)
+		var a mitmengine.Processor
+		a.Store(&mitmengine.Snapshot{BrowserDatabase: browserDatabase})
+		fingerprint, err := fp.NewRequestFingerprint(test.fingerprint)
+		testutil.Ok(t, err)
+		actual := a.Check(uaFingerprint, "", fingerprint)
+		testutil.Ok(t, actual.Error)
+		testutil.Assert(t, actual.BrowserSignatureMatch != fp.MatchPossible, "expected mismatch for %s", test.fingerprint)
+		testutil.Equals(t, test.losesPfs, actual.LosesPfs)
+	}
+}
+
+// Check that the browser and actual grades are explained by their factors,
+// including the matched MITM software
+func TestProcessorCheckGradeExplanation(t *testing.T) {
+	browserDatabase, err := db.NewDatabase(strings.NewReader("1:72:2:3:10.14:1:|303:c02b,c02f:*:*:*:*:*|:0:0"))
+	testutil.Ok(t, err)
+	mitmDatabase, err := db.NewDatabase(strings.NewReader("1:72:2:3:10.14:1:|300:5:*:*:*:*:*|badproxy:1:4"))
+	testutil.Ok(t, err)
+	var a mitmengine.Processor
+	a.Store(&mitmengine.Snapshot{BrowserDatabase: browserDatabase, MitmDatabase: mitmDatabase})
+	uaFingerprint, err := fp.NewUAFingerprint("1:72.0.3626:2:3:10.14.3:1:")
+	testutil.Ok(t, err)
+	fingerprint, err := fp.NewRequestFingerprint("300:5:0:1d:0::")
+	testutil.Ok(t, err)
+	actual := a.Check(uaFingerprint, "", fingerprint)
+	testutil.Ok(t, actual.Error)
+	testutil.Equals(t, "badproxy", actual.MatchedMitmName)
+	testutil.Equals(t, fp.GradeExplanation{Grade: fp.GradeA, Factors: []fp.GradeFactor{
+		{Name: fp.GradeFactorFirstCipher, Value: "c02b", Grade: fp.GradeA},
+	}}, actual.BrowserGradeExplanation)
+	testutil.Equals(t, fp.GradeExplanation{Grade: fp.GradeF, Factors: []fp.GradeFactor{
+		{Name: fp.GradeFactorVersion, Value: "300", Grade: fp.GradeC},
+		{Name: fp.FindingKnownAttackCipher, Value: "5", Grade: fp.GradeC},
+		{Name: fp.GradeFactorMitm, Value: "badproxy", Grade: fp.GradeF},
+	}}, actual.ActualGradeExplanation)
+	testutil.Equals(t, actual.BrowserGradeExplanation.Grade, actual.BrowserGrade)
+	testutil.Equals(t, actual.ActualGradeExplanation.Grade, actual.ActualGrade)
+}
+
+// Check that a processor uses the cipher check from its config instead of the
+// global cipher check
+func TestProcessorConfigCipherCheck(t *testing.T) {
+	registry := fp.DefaultRegistry()
+	testutil.Ok(t, registry.ReadCSV(fp.RegistryCipherSuites, strings.NewReader("Value,Description,Grade,PFS,AEAD\n0xC02B,TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,F,Y,Y\n")))
+	cipherCheck := fp.NewCipherCheckFromRegistry(registry)
+	config := mitmengine.Config{
+		BrowserFileName:   "browser.txt",
+		MitmFileName:      "mitm.txt",
+		BadHeaderFileName: "badheader.txt",
+		Loader: testutil.MapLoader{
+			"browser.txt":   []byte("1:72:2:3:10.14:1:|303:c02b,c02f:*:*:*:*:*|:0:0"),
+			"mitm.txt":      []byte(""),
+			"badheader.txt": []byte(""),
+		},
+	}
+	uaFingerprint, err := fp.NewUAFingerprint("1:72.0.3626:2:3:10.14.3:1:")
+	testutil.Ok(t, err)
+	fingerprint, err := fp.NewRequestFingerprint("303:c02b,c02f:ff01:1d:0::")
+	testutil.Ok(t, err)
+
+	a, err := mitmengine.NewProcessor(&config)
+	testutil.Ok(t, err)
+	actual := a.Check(uaFingerprint, "", fingerprint)
+	testutil.Equals(t, fp.GradeA, actual.BrowserGrade)
+	testutil.Equals(t, fp.GradeA, actual.ActualGrade)
+	testutil.Equals(t, false, actual.WeakCiphers)
+
+	config.CipherCheck = &cipherCheck
+	testutil.Ok(t, a.Load(&config))
+	testutil.Equals(t, &cipherCheck, a.Snapshot().CipherCheck)
+	actual = a.Check(uaFingerprint, "", fingerprint)
+	testutil.Equals(t, fp.GradeF, actual.BrowserGrade)
+	testutil.Equals(t, fp.GradeF, actual.ActualGrade)
+	testutil.Equals(t, true, actual.WeakCiphers)
+
+	// a refresh applies a new cipher check even if no files have changed
+	config.CipherCheck = nil
+	changed, err := a.Refresh(&config)
+	testutil.Ok(t, err)
+	testutil.Assert(t, changed, "expected refresh")
+	testutil.Equals(t, (*fp.CipherCheck)(nil), a.Snapshot().CipherCheck)
+	actual = a.Check(uaFingerprint, "", fingerprint)
+	testutil.Equals(t, fp.GradeA, actual.ActualGrade)
+	changed, err = a.Refresh(&config)
+	testutil.Ok(t, err)
+	testutil.Assert(t, !changed, "unexpected refresh")
+}
+
+func TestProcessorDeprecatedAccessors(t *testing.T) {
+	var a mitmengine.Processor
+	testutil.Equals(t, 0, len(a.BrowserDatabase().Records))
+	testutil.Equals(t, 0, len(a.MitmDatabase().Records))
+	testutil.Equals(t, fp.StringSet(nil), a.BadHeaderSet())
+
+	a, err := mitmengine.NewProcessor(&mitmengine.Config{
+		BrowserFileName:   "browser.txt",
+		MitmFileName:      "mitm.txt",
+		BadHeaderFileName: "badheader.txt",
+		Loader: testutil.MapLoader{
+			"browser.txt":   []byte("1:72:2:3:10.14:1:|303:c02b,c02f:0,a:1d:0:*:*|:0:0"),
+			"mitm.txt":      []byte(""),
+			"badheader.txt": []byte("x-bluecoat-via"),
+		},
+	})
+	testutil.Ok(t, err)
+	snapshot := a.Snapshot()
+	testutil.Equals(t, snapshot.BrowserDatabase, a.BrowserDatabase())
+	testutil.Equals(t, snapshot.MitmDatabase, a.MitmDatabase())
+	testutil.Equals(t, snapshot.BadHeaderSet, a.BadHeaderSet())
+}
+
+// Check that a failed reload keeps the previous snapshot
+func TestProcessorReload(t *testing.T) {
+	files := testutil.MapLoader{
+		"browser.txt":   []byte("1:72:2:3:10.14:1:|303:c02b,c02f:0,a:1d:0:*:*|:0:0"),
+		"mitm.txt":      []byte(""),
+		"badheader.txt": []byte("x-bluecoat-via"),
+	}
+	config := mitmengine.Config{
+		BrowserFileName:   "browser.txt",
+		MitmFileName:      "mitm.txt",
+		BadHeaderFileName: "badheader.txt",
+		Loader:            files,
+	}
+	a, err := mitmengine.NewProcessor(&config)
+	testutil.Ok(t, err)
+	uaFingerprint, err := fp.NewUAFingerprint("1:72.0.3626:2:3:10.14.3:1:")
+	testutil.Ok(t, err)
+	fingerprint, err := fp.NewRequestFingerprint("303:c02b,c02f:0,a:1d:0::")
+	testutil.Ok(t, err)
+	testutil.Equals(t, fp.MatchPossible, a.Check(uaFingerprint, "", fingerprint).BrowserSignatureMatch)
+	previous := a.Snapshot()
+
+	// copies of the processor share the reloaded state
+	b := a
+	files["browser.txt"] = []byte("1:72:2:3:10.14:1:|303:c02b:0,a:1d:0:*:*|:0:0")
+	testutil.Ok(t, b.Load(&config))
+	testutil.Assert(t, a.Snapshot() != previous, "snapshot was not replaced")
+	testutil.Equals(t, fp.MatchImpossible, a.Check(uaFingerprint, "", fingerprint).BrowserSignatureMatch)
+	previous = a.Snapshot()
+
+	// a file that cannot be parsed keeps the previous snapshot
+	files["browser.txt"] = []byte("1:72:2:3:10.14:1:|bad")
+	testutil.Assert(t, a.Load(&config) != nil, "expected error for bad browser file")
+	testutil.Equals(t, previous, a.Snapshot())
+
+	// a file that cannot be loaded keeps the previous snapshot
+	files["browser.txt"] = []byte("1:72:2:3:10.14:1:|303:c02b,c02f:0,a:1d:0:*:*|:0:0")
+	delete(files, "badheader.txt")
+	testutil.Assert(t, a.Load(&config) != nil, "expected error for missing bad header file")
+	testutil.Equals(t, previous, a.Snapshot())
+	testutil.Equals(t, fp.MatchImpossible, a.Check(uaFingerprint, "", fingerprint).BrowserSignatureMatch)
+}
+
+// Check that reloading the processor while checking requests always uses a
+// consistent set of databases
+func TestProcessorReloadConcurrent(t *testing.T) {
+	var tests = []struct {
+		browser string
+		match   fp.Match
+	}{
+		{"1:72:2:3:10.14:1:|303:c02b,c02f:0,a:1d:0:*:*|:0:0", fp.MatchPossible},
+		{"1:72:2:3:10.14:1:|303:c02b:0,a:1d:0:*:*|:0:0", fp.MatchImpossible},
+	}
+	files := testutil.MapLoader{"mitm.txt": []byte(""), "badheader.txt": []byte("")}
+	var configs []mitmengine.Config
+	for idx, test := range tests {
+		fileName := fmt.Sprintf("browser%d.txt", idx)
+		files[fileName] = []byte(test.browser)
+		configs = append(configs, mitmengine.Config{
+			BrowserFileName:   fileName,
+			MitmFileName:      "mitm.txt",
+			BadHeaderFileName: "badheader.txt",
+			Loader:            files,
+		})
+	}
+	a, err := mitmengine.NewProcessor(&configs[0])
+	testutil.Ok(t, err)
+	uaFingerprint, err := fp.NewUAFingerprint("1:72.0.3626:2:3:10.14.3:1:")
+	testutil.Ok(t, err)
+	// every check shares the fingerprint, which has GREASE values to remove
+	// and room to append quirks in place
+	fingerprint, err := fp.NewRequestFingerprint("303:a0a,c02b,c02f:0,a,1a1a:1d:0::")
+	testutil.Ok(t, err)
+	fingerprint.Quirk = make(fp.StringList, 0, 4)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				report := a.Check(uaFingerprint, "", fingerprint)
+				if report.Error != nil || report.BrowserSignatureMatch == fp.MatchUnlikely {
+					errs <- fmt.Errorf("unexpected report: %+v", report)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		testutil.Ok(t, a.Load(&configs[i%len(configs)]))
+	}
+	close(done)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,81 @@
+package mitmengine
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloudflare/mitmengine/loader"
+)
+
+// Refresh reloads the processor state from the configuration if any of the
+// files or the CipherCheck have changed since the current snapshot was
+// loaded, and returns true if a new snapshot was stored. Files that have not
+// changed are not parsed again, and are not downloaded again if the loader
+// implements loader.ConditionalLoader. If any file cannot be loaded or parsed,
+// the current snapshot is kept and the error is returned. The config's OnReload
+// and OnReloadError callbacks are called with the result.
+func (a *Processor) Refresh(config *Config) (bool, error) {
+	return a.RefreshContext(context.Background(), config)
+}
+
+// RefreshContext refreshes the processor state as described for Refresh, and
+// stops loading files when the context is done.
+func (a *Processor) RefreshContext(ctx context.Context, config *Config) (bool, error) {
+	if a.state == nil {
+		a.state = newProcessorState()
+	}
+	ctx, cancel := config.loadContext(ctx)
+	defer cancel()
+	a.state.loadMutex.Lock()
+	snapshot, err := loadSnapshot(ctx, config, a.Snapshot(), false)
+	if err == nil {
+		a.state.snapshot.Store(snapshot)
+	}
+	a.state.loadMutex.Unlock()
+
+	switch err {
+	case nil:
+		if config.OnReload != nil {
+			config.OnReload(snapshot)
+		}
+		return true, nil
+	case loader.ErrNotModified:
+		return false, nil
+	default:
+		if config.OnReloadError != nil {
+			config.OnReloadError(err)
+		}
+		return false, err
+	}
+}
+
+// Close stops refreshing the processor in the background, and cancels a
+// background refresh that is in progress. The processor can still be used to
+// check requests.
+func (a Processor) Close() {
+	if a.state == nil {
+		return
+	}
+	a.state.stopOnce.Do(func() { close(a.state.stop) })
+}
+
+// refreshLoop refreshes the processor at the configured interval until the
+// processor is closed.
+func (a Processor) refreshLoop(config Config) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-a.state.stop
+		cancel()
+	}()
+	ticker := time.NewTicker(config.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.RefreshContext(ctx, &config)
+		case <-a.state.stop:
+			return
+		}
+	}
+}
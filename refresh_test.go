@@ -0,0 +1,195 @@
+package mitmengine_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/mitmengine"
+	"github.com/cloudflare/mitmengine/loader"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+// versionLoader loads files from a map of file names to file contents, using
+// the quoted contents as the file version, and counts the files it returns.
+type versionLoader struct {
+	sync.Mutex
+	files map[string]string
+	loads int
+}
+
+func (a *versionLoader) LoadFile(fileName string) (io.ReadCloser, error) {
+	file, _, err := a.LoadFileIfChanged(fileName, "")
+	return file, err
+}
+
+func (a *versionLoader) LoadFileIfChanged(fileName string, version string) (io.ReadCloser, string, error) {
+	a.Lock()
+	defer a.Unlock()
+	contents, ok := a.files[fileName]
+	if !ok {
+		return nil, "", errors.New("file not found: " + fileName)
+	}
+	if strconv.Quote(contents) == version {
+		return nil, version, loader.ErrNotModified
+	}
+	a.loads++
+	return ioutil.NopCloser(strings.NewReader(contents)), strconv.Quote(contents), nil
+}
+
+func (a *versionLoader) set(fileName, contents string) {
+	a.Lock()
+	a.files[fileName] = contents
+	a.Unlock()
+}
+
+func newRefreshConfig(dbReader loader.Loader) mitmengine.Config {
+	return mitmengine.Config{
+		BrowserFileName:   "browser.txt",
+		MitmFileName:      "mitm.txt",
+		BadHeaderFileName: "badheader.txt",
+		Loader:            dbReader,
+	}
+}
+
+func TestProcessorRefresh(t *testing.T) {
+	files := testutil.MapLoader{
+		"browser.txt":   []byte("1:72:2:3:10.14:1:|303:c02b,c02f:0,a:1d:0:*:*|:0:0"),
+		"mitm.txt":      []byte(""),
+		"badheader.txt": []byte("x-bluecoat-via"),
+	}
+	var reloaded []*mitmengine.Snapshot
+	var reloadErrors []error
+	config := newRefreshConfig(files)
+	config.OnReload = func(snapshot *mitmengine.Snapshot) { reloaded = append(reloaded, snapshot) }
+	config.OnReloadError = func(err error) { reloadErrors = append(reloadErrors, err) }
+	a, err := mitmengine.NewProcessor(&config)
+	testutil.Ok(t, err)
+	first := a.Snapshot()
+	testutil.Assert(t, !first.LoadedAt.IsZero(), "load time not recorded")
+	testutil.Assert(t, first.Version != "", "version not recorded")
+	testutil.Equals(t, 3, len(first.FileVersions))
+
+	// nothing changed
+	changed, err := a.Refresh(&config)
+	testutil.Ok(t, err)
+	testutil.Assert(t, !changed, "unexpected refresh")
+	testutil.Equals(t, first, a.Snapshot())
+	testutil.Equals(t, 0, len(reloaded))
+
+	// only the changed file is parsed again
+	files["badheader.txt"] = []byte("x-bluecoat-via\nx-forwarded-for")
+	changed, err = a.Refresh(&config)
+	testutil.Ok(t, err)
+	testutil.Assert(t, changed, "expected refresh")
+	second := a.Snapshot()
+	testutil.Equals(t, []*mitmengine.Snapshot{second}, reloaded)
+	testutil.Assert(t, second.Version != first.Version, "version not updated")
+	testutil.Assert(t, &second.BrowserDatabase.Records[0] == &first.BrowserDatabase.Records[0], "unchanged database was parsed again")
+	testutil.Assert(t, second.BadHeaderSet["x-forwarded-for"], "changed file was not loaded")
+
+	// a failed refresh keeps the current snapshot
+	files["browser.txt"] = []byte("1:72:2:3:10.14:1:|bad")
+	changed, err = a.Refresh(&config)
+	testutil.Assert(t, err != nil, "expected error for bad browser file")
+	testutil.Assert(t, !changed, "unexpected refresh")
+	testutil.Equals(t, second, a.Snapshot())
+	testutil.Equals(t, []error{err}, reloadErrors)
+}
+
+func TestProcessorRefreshConditional(t *testing.T) {
+	files := &versionLoader{files: map[string]string{
+		"browser.txt":   "1:72:2:3:10.14:1:|303:c02b,c02f:0,a:1d:0:*:*|:0:0",
+		"mitm.txt":      "",
+		"badheader.txt": "x-bluecoat-via",
+	}}
+	config := newRefreshConfig(files)
+	a, err := mitmengine.NewProcessor(&config)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 3, files.loads)
+	testutil.Equals(t, `"x-bluecoat-via"`, a.Snapshot().FileVersions["badheader.txt"])
+
+	changed, err := a.Refresh(&config)
+	testutil.Ok(t, err)
+	testutil.Assert(t, !changed, "unexpected refresh")
+	testutil.Equals(t, 3, files.loads)
+
+	files.set("mitm.txt", "0::0:0::0:|303:*:*:*:*:*x-bluecoat-via:*|:0:0")
+	changed, err = a.Refresh(&config)
+	testutil.Ok(t, err)
+	testutil.Assert(t, changed, "expected refresh")
+	testutil.Equals(t, 4, files.loads)
+	testutil.Equals(t, 1, a.Snapshot().MitmDatabase.Len())
+}
+
+func TestProcessorRefreshInterval(t *testing.T) {
+	files := &versionLoader{files: map[string]string{
+		"browser.txt":   "",
+		"mitm.txt":      "",
+		"badheader.txt": "",
+	}}
+	reloaded := make(chan *mitmengine.Snapshot, 1)
+	config := newRefreshConfig(files)
+	config.RefreshInterval = time.Millisecond
+	config.OnReload = func(snapshot *mitmengine.Snapshot) { reloaded <- snapshot }
+	a, err := mitmengine.NewProcessor(&config)
+	testutil.Ok(t, err)
+	defer a.Close()
+	files.set("badheader.txt", "x-bluecoat-via")
+	select {
+	case snapshot := <-reloaded:
+		testutil.Equals(t, snapshot, a.Snapshot())
+		testutil.Assert(t, snapshot.BadHeaderSet["x-bluecoat-via"], "changed file was not loaded")
+	case <-time.After(10 * time.Second):
+		t.Fatal("processor was not refreshed")
+	}
+}
+
+func TestProcessorLoadTimeout(t *testing.T) {
+	blocked := make(chan struct{})
+	defer close(blocked)
+	files := testutil.NewBlockingLoader(testutil.MapLoader{}, blocked)
+	config := newRefreshConfig(files)
+	config.LoadTimeout = 10 * time.Millisecond
+	a, err := mitmengine.NewProcessor(&config)
+	testutil.Assert(t, errors.Is(err, context.DeadlineExceeded), "expected deadline error, got %v", err)
+	testutil.Assert(t, a.Snapshot() == nil, "expected no snapshot")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = mitmengine.NewProcessorContext(ctx, &config)
+	testutil.Assert(t, errors.Is(err, context.Canceled), "expected cancellation error, got %v", err)
+}
+
+func TestProcessorCloseCancelsRefresh(t *testing.T) {
+	files := testutil.NewBlockingLoader(testutil.MapLoader{"browser.txt": []byte(""), "mitm.txt": []byte(""), "badheader.txt": []byte("")}, nil)
+	errs := make(chan error, 1)
+	config := newRefreshConfig(files)
+	config.RefreshInterval = time.Millisecond
+	config.OnReloadError = func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+	a, err := mitmengine.NewProcessor(&config)
+	testutil.Ok(t, err)
+	blocked := make(chan struct{})
+	defer close(blocked)
+	files.Block(blocked)
+	time.Sleep(10 * time.Millisecond)
+	a.Close()
+	select {
+	case err := <-errs:
+		testutil.Assert(t, errors.Is(err, context.Canceled), "expected cancellation error, got %v", err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("refresh was not cancelled")
+	}
+	testutil.Assert(t, a.Snapshot() != nil, "expected previous snapshot")
+}
@@ -17,25 +17,45 @@ type Report struct {
 	// versus the browser signature
 	BrowserSignatureMatch fp.Match
 
-	// Reason for mismatch between actual fingerprint and expected signature
+	// Reason for mismatch between actual fingerprint and expected signature,
+	// for the first impossible finding or else the first unlikely finding
 	Reason string
 
 	// ReasonDetails supplied additional details for the above reason
 	ReasonDetails string
 
+	// Findings lists each field of the actual fingerprint that does not
+	// match the browser signature
+	Findings []Finding
+
 	// BrowserGrade is the expected security grade for the browser without interference
 	BrowserGrade fp.Grade
 
+	// BrowserGradeExplanation lists the factors of BrowserGrade
+	BrowserGradeExplanation fp.GradeExplanation
+
 	// Actual security grade of the request
 	ActualGrade fp.Grade
 
+	// ActualGradeExplanation lists the factors of ActualGrade, including the
+	// matched MITM software
+	ActualGradeExplanation fp.GradeExplanation
+
 	// WeakCiphers is true if the request contains weak ciphers
 	WeakCiphers bool
 
+	// Security lists the security weaknesses of the request. Its grade is
+	// separate from ActualGrade, which is comparable to BrowserGrade
+	Security fp.SecurityAssessment
+
 	// LosesPfs is true if a MITM causes the request to lose perfect
 	// forward secrecy
 	LosesPfs bool
 
+	// VersionDowngrade is true if the browser signature requires TLS 1.3
+	// support, but the request does not offer TLS 1.3
+	VersionDowngrade bool
+
 	// MatchedMitmSignature is the signature of the MITM software if matched
 	MatchedMitmSignature string
 
@@ -45,7 +65,46 @@ type Report struct {
 	// MatchedMitmType classification of the MITM software if matched
 	MatchedMitmType uint8
 
+	// JA4 is the JA4 fingerprint of the request
+	JA4 string
+
 	// Error is set if the user agent does not indicate a supported browser, or
 	// does not match any known user agent signature
 	Error error
 }
+
+// A Finding describes a field of a request fingerprint that does not match
+// the expected browser signature. Elements are formatted as in signatures,
+// with integers in hex.
+type Finding struct {
+
+	// Field is the name of the fingerprint field, as used in Report.Reason
+	Field string
+
+	// Match is the match result of the field versus the signature
+	Match fp.Match
+
+	// Expected is the signature for the field
+	Expected string
+
+	// Actual is the value of the field in the fingerprint
+	Actual string
+
+	// Missing lists required elements that are not in the fingerprint
+	Missing []string
+
+	// Extra lists elements in the fingerprint that the signature does not
+	// allow
+	Extra []string
+
+	// Excluded lists elements in the fingerprint that the signature excludes
+	Excluded []string
+
+	// Unlikely lists elements in the fingerprint that the signature marks as
+	// unlikely
+	Unlikely []string
+
+	// OutOfOrder is the first pair of elements in the fingerprint that the
+	// signature orders the other way around
+	OutOfOrder []string
+}
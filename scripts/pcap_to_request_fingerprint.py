@@ -12,6 +12,9 @@ class RequestFingerprint:
         self.ciphersuites = []
         self.compression_methods = []
         self.signature_algorithms = []
+        self.alpn_protocols = []
+        self.supported_versions = []
+        self.key_shares = []
         self.extensions = []
         self.supported_groups = []
         self.ec_point_formats = []
@@ -21,13 +24,16 @@ class RequestFingerprint:
     def __str__(self):
         if len(self.compression_methods) > 1:
             self.quirks.append("compr")
-        return "{version}:{ciphersuites}:{extensions}:{supported_groups}:{ec_point_formats}:{headers}:{quirks}".format(
+        return "{version}:{ciphersuites}:{extensions}:{supported_groups}:{ec_point_formats}:{headers}:{quirks}:{signature_algorithms}:{alpn_protocols}:{supported_versions}:{key_shares}".format(
             version="{:x}".format(int(self.tls_version,16)),
             ciphersuites=",".join("{:x}".format(int(x)) for x in self.ciphersuites),
             extensions=",".join("{:x}".format(int(x)) for x in self.extensions),
             supported_groups=",".join("{:x}".format(int(x,16)) for x in self.supported_groups),
             ec_point_formats=",".join("{:x}".format(int(x)) for x in self.ec_point_formats),
-            #signature_algorithms=",".join("{:x}".format(int(x,16)) for x in self.signature_algorithms), # not currently used in fingerprint
+            signature_algorithms=",".join("{:x}".format(int(x,16)) for x in self.signature_algorithms),
+            alpn_protocols=",".join(self.alpn_protocols),
+            supported_versions=",".join("{:x}".format(int(x,16)) for x in self.supported_versions),
+            key_shares=",".join("{:x}".format(int(x)) for x in self.key_shares),
             headers=",".join(self.headers), quirks=",".join(self.quirks)) 
 
     def parse(self, filename):
@@ -39,7 +45,10 @@ class RequestFingerprint:
             "-e", "tls.handshake.extension.type",
             "-e", "tls.handshake.extensions_supported_group",
             "-e", "tls.handshake.extensions_ec_point_format",
-            "-e", "tls.handshake.sig_hash_alg"], capture_output=True, encoding='utf-8')
+            "-e", "tls.handshake.sig_hash_alg",
+            "-e", "tls.handshake.extensions_alpn_str",
+            "-e", "tls.handshake.extensions.supported_version",
+            "-e", "tls.handshake.extensions_key_share_group"], capture_output=True, encoding='utf-8')
 
         if tshark.returncode != 0:
             print(tshark.stderr)
@@ -60,6 +69,12 @@ class RequestFingerprint:
             self.ec_point_formats = record["tls.handshake.extensions_ec_point_format"]
         if '13' in self.extensions:
             self.signature_algorithms = record["tls.handshake.sig_hash_alg"]
+        if '16' in self.extensions:
+            self.alpn_protocols = record["tls.handshake.extensions_alpn_str"]
+        if '43' in self.extensions:
+            self.supported_versions = record["tls.handshake.extensions.supported_version"]
+        if '51' in self.extensions:
+            self.key_shares = record["tls.handshake.extensions_key_share_group"]
 
 if __name__ == "__main__":
     import argparse
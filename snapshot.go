@@ -0,0 +1,212 @@
+package mitmengine
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/mitmengine/db"
+	fp "github.com/cloudflare/mitmengine/fputil"
+	"github.com/cloudflare/mitmengine/loader"
+)
+
+// versionLen is the length of a snapshot version.
+const versionLen int = 16
+
+// A Snapshot contains the databases that a Processor uses to check requests.
+// A Snapshot must not be modified after it is stored in a Processor, since
+// Check may be reading it concurrently.
+//
+// LoadedAt is the time the snapshot was loaded, and Version identifies the
+// contents of the files it was loaded from. FileVersions maps the name of each
+// loaded file to its version, which is the version reported by a
+// loader.ConditionalLoader or the SHA-256 hash of the file contents. Source is
+// the name of the source that the files were loaded from if the loader is a
+// loader.SourceLoader, such as a loader.Fallback, and is empty otherwise.
+//
+// CipherCheck is the Config.CipherCheck that the snapshot was loaded with, and
+// fp.GlobalCipherCheck is used if it is nil.
+type Snapshot struct {
+	BrowserDatabase db.Database
+	MitmDatabase    db.Database
+	BadHeaderSet    fp.StringSet
+	CipherCheck     *fp.CipherCheck
+
+	LoadedAt     time.Time
+	Version      string
+	FileVersions map[string]string
+	Source       string
+}
+
+// loadSnapshot returns a new snapshot loaded from the configuration. If
+// previous is not nil, files that have not changed since the previous
+// snapshot was loaded are not parsed again, and loader.ErrNotModified is
+// returned if neither the files nor the config's CipherCheck have changed. If allowMissing is true, files that
+// cannot be loaded are logged and treated as empty instead of returning an
+// error, unless the context is done. Files loaded from a bundle are committed
+// to the loader, as described for loader.Committer, only once all of them
+// have been parsed.
+func loadSnapshot(ctx context.Context, config *Config, previous *Snapshot, allowMissing bool) (*Snapshot, error) {
+	snapshot := &Snapshot{CipherCheck: config.CipherCheck, FileVersions: make(map[string]string)}
+	var previousVersions map[string]string
+	if previous != nil {
+		previousVersions = previous.FileVersions
+	}
+
+	// Load all files at once from a bundle loader, so that a bundle that fails
+	// verification is rejected as a whole.
+	dbReader := config.Loader
+	fileNames := []string{config.BrowserFileName, config.MitmFileName, config.BadHeaderFileName}
+	var files map[string][]byte
+	var err error
+	switch dbReader.(type) {
+	case loader.SourceLoader:
+		files, snapshot.Source, err = loader.LoadBundleSourceContext(ctx, dbReader, fileNames)
+		if err != nil {
+			return nil, err
+		}
+		dbReader = bundleFiles(files)
+	case loader.BundleLoader:
+		files, err = loader.LoadBundleContext(ctx, dbReader, fileNames)
+		if err != nil {
+			return nil, err
+		}
+		dbReader = bundleFiles(files)
+	}
+	// a change of source or cipher check is reported even if the files are
+	// the same
+	changed := previous != nil && (previous.Source != snapshot.Source || previous.CipherCheck != snapshot.CipherCheck)
+
+	data, err := snapshot.loadFile(ctx, config.BrowserFileName, dbReader, previousVersions, allowMissing)
+	switch err {
+	case nil:
+		changed = true
+		if snapshot.BrowserDatabase, err = db.NewDatabase(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	case loader.ErrNotModified:
+		snapshot.BrowserDatabase = previous.BrowserDatabase
+	default:
+		return nil, err
+	}
+
+	data, err = snapshot.loadFile(ctx, config.MitmFileName, dbReader, previousVersions, allowMissing)
+	switch err {
+	case nil:
+		changed = true
+		if snapshot.MitmDatabase, err = db.NewDatabase(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	case loader.ErrNotModified:
+		snapshot.MitmDatabase = previous.MitmDatabase
+	default:
+		return nil, err
+	}
+
+	data, err = snapshot.loadFile(ctx, config.BadHeaderFileName, dbReader, previousVersions, allowMissing)
+	switch err {
+	case nil:
+		changed = true
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		var badHeaderList fp.StringList
+		for scanner.Scan() {
+			badHeaderList = append(badHeaderList, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		snapshot.BadHeaderSet = badHeaderList.Set()
+	case loader.ErrNotModified:
+		snapshot.BadHeaderSet = previous.BadHeaderSet
+	default:
+		return nil, err
+	}
+
+	// a failure to commit does not reject files that parsed successfully
+	if files != nil {
+		if err := loader.Commit(config.Loader, files); err != nil {
+			log.Printf("WARNING: committing files produced error \"%s\"", err)
+		}
+	}
+	if !changed {
+		return nil, loader.ErrNotModified
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		snapshot.FileVersions[config.BrowserFileName],
+		snapshot.FileVersions[config.MitmFileName],
+		snapshot.FileVersions[config.BadHeaderFileName],
+	}, "\n")))
+	snapshot.Version = hex.EncodeToString(sum[:])[:versionLen]
+	snapshot.LoadedAt = time.Now()
+	return snapshot, nil
+}
+
+// cipherCheck returns the cipher check of the snapshot, or the global cipher
+// check if it does not have one.
+func (a *Snapshot) cipherCheck() *fp.CipherCheck {
+	if a.CipherCheck == nil {
+		return &fp.GlobalCipherCheck
+	}
+	return a.CipherCheck
+}
+
+// loadFile loads a file and records its version in the snapshot. If the file
+// has the same version as in previousVersions, loader.ErrNotModified is
+// returned. If allowMissing is true and the file cannot be loaded, a warning
+// is logged and the file is treated as empty, unless the context is done.
+func (a *Snapshot) loadFile(ctx context.Context, fileName string, dbReader loader.Loader, previousVersions map[string]string, allowMissing bool) ([]byte, error) {
+	previousVersion := previousVersions[fileName]
+	var file io.ReadCloser
+	var version string
+	var err error
+	if dbReader != nil {
+		file, version, err = loader.LoadFileIfChangedContext(ctx, dbReader, fileName, previousVersion)
+	} else {
+		file, err = LoadFile(fileName, dbReader)
+	}
+	if err == loader.ErrNotModified && previousVersion != "" {
+		a.FileVersions[fileName] = previousVersion
+		return nil, err
+	}
+	if err != nil {
+		if !allowMissing || ctx.Err() != nil {
+			return nil, fmt.Errorf("loading file \"%s\": %w", fileName, err)
+		}
+		log.Printf("WARNING: loading file \"%s\" produced error \"%s\"", fileName, err)
+		return nil, nil
+	}
+	data, err := ioutil.ReadAll(file)
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("reading file \"%s\": %w", fileName, err)
+	}
+	if version == "" {
+		sum := sha256.Sum256(data)
+		version = hex.EncodeToString(sum[:])
+	}
+	a.FileVersions[fileName] = version
+	if version == previousVersion {
+		return nil, loader.ErrNotModified
+	}
+	return data, nil
+}
+
+// bundleFiles implements interface loader.Loader for the files returned by a
+// loader.BundleLoader.
+type bundleFiles map[string][]byte
+
+func (a bundleFiles) LoadFile(fileName string) (io.ReadCloser, error) {
+	contents, ok := a[fileName]
+	if !ok {
+		return nil, fmt.Errorf("file not in bundle: %s", fileName)
+	}
+	return ioutil.NopCloser(bytes.NewReader(contents)), nil
+}
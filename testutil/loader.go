@@ -0,0 +1,57 @@
+package testutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// MapLoader loads files from a map of file names to file contents.
+type MapLoader map[string][]byte
+
+// LoadFile returns the contents of the file, or an error if there is no file
+// with the name.
+func (a MapLoader) LoadFile(fileName string) (io.ReadCloser, error) {
+	contents, ok := a[fileName]
+	if !ok {
+		return nil, fmt.Errorf("file not found: %s", fileName)
+	}
+	return ioutil.NopCloser(bytes.NewReader(contents)), nil
+}
+
+// BlockingLoader loads files from a MapLoader until it is blocked, and then
+// blocks every load until the channel it was blocked with is closed.
+type BlockingLoader struct {
+	mutex   sync.Mutex
+	files   MapLoader
+	blocked chan struct{}
+}
+
+// NewBlockingLoader returns a loader for the files that is blocked with the
+// channel, or that is not blocked if the channel is nil.
+func NewBlockingLoader(files MapLoader, blocked chan struct{}) *BlockingLoader {
+	return &BlockingLoader{files: files, blocked: blocked}
+}
+
+// LoadFile returns the contents of the file, or blocks until the channel the
+// loader was blocked with is closed and returns an error.
+func (a *BlockingLoader) LoadFile(fileName string) (io.ReadCloser, error) {
+	a.mutex.Lock()
+	blocked := a.blocked
+	a.mutex.Unlock()
+	if blocked != nil {
+		<-blocked
+		return nil, errors.New("unblocked")
+	}
+	return a.files.LoadFile(fileName)
+}
+
+// Block blocks every later load until the channel is closed.
+func (a *BlockingLoader) Block(blocked chan struct{}) {
+	a.mutex.Lock()
+	a.blocked = blocked
+	a.mutex.Unlock()
+}